@@ -0,0 +1,13 @@
+package db
+
+import "example.com/shop/internal/core"
+
+// Store keeps the orders in memory.
+type Store struct {
+	orders map[string]core.Order
+}
+
+func (s *Store) Save(order core.Order) error {
+	s.orders[order.ID] = order
+	return nil
+}
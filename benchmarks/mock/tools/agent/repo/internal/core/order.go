@@ -0,0 +1,12 @@
+package core
+
+// Order is placed by a customer.
+type Order struct {
+	ID    string
+	Total int
+}
+
+// OrderStore persists orders, implemented by an adapter.
+type OrderStore interface {
+	Save(order Order) error
+}
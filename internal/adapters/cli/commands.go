@@ -1,24 +1,47 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strings"
+	"strconv"
+	"syscall"
 	"text/tabwriter"
+	"time"
 
 	"github.com/ingo-eichhorst/arch-bench/internal/adapters/config"
 	"github.com/ingo-eichhorst/arch-bench/internal/adapters/llm"
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/report"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
 	"github.com/ingo-eichhorst/arch-bench/internal/core/ports"
 	"github.com/ingo-eichhorst/arch-bench/internal/core/services"
 	"github.com/spf13/cobra"
 )
 
-func NewRootCmd(cfg *config.Config) *cobra.Command {
+func NewRootCmd() *cobra.Command {
+	// Loaded once the flags are parsed, the commands below only use it when they run
+	cfg := &config.Config{}
 	rootCmd := &cobra.Command{
 		Use:   "arch-bench",
 		Short: "CLI for software architecture benchmark for LLMs and LVMs",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			benchmarksDir, _ := cmd.Flags().GetString("benchmarks-dir")
+			configFile, _ := cmd.Flags().GetString("config")
+			setFlags, _ := cmd.Flags().GetStringArray("set")
+			loaded, err := config.LoadConfig(benchmarksDir, configFile, setFlags)
+			if err != nil {
+				return fmt.Errorf("error loading config: %v", err)
+			}
+			*cfg = *loaded
+			return nil
+		},
 	}
+	rootCmd.PersistentFlags().String("benchmarks-dir", "", "Directory of the benchmarks (default: $"+config.HomeEnv+"/benchmarks or a benchmarks directory in the working directory, next to the binary or in one of their parents)")
+	rootCmd.PersistentFlags().String("config", "", "Global config file (default: "+config.ConfigFileName+" in the home directory or the user config directory)")
+	rootCmd.PersistentFlags().StringArray("set", nil, "Override a setting, e.g. --set eval_model=gpt-4o or --set providers.openai.base_url=http://localhost:8080/v1")
 
 	runCmd := &cobra.Command{
 		Use:   "run <benchmark-name>",
@@ -27,10 +50,92 @@ func NewRootCmd(cfg *config.Config) *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			benchmarkName := args[0]
 			testSuiteName, _ := cmd.Flags().GetString("test-suite")
-			return runBenchmark(cfg, benchmarkName, testSuiteName)
+			repeat, _ := cmd.Flags().GetInt("repeat")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			estimate, _ := cmd.Flags().GetBool("estimate")
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+			callTimeout, _ := cmd.Flags().GetDuration("call-timeout")
+			failFast, _ := cmd.Flags().GetBool("fail-fast")
+			cacheMode, _ := cmd.Flags().GetString("cache")
+			replayMode, _ := cmd.Flags().GetString("replay")
+			setDurationFlag(cfg, config.KeyRunTimeoutSeconds, timeout, "--timeout")
+			setDurationFlag(cfg, config.KeyCallTimeoutSeconds, callTimeout, "--call-timeout")
+			if cacheMode != "" {
+				cfg.SetFlag(config.KeyCache, cacheMode, "--cache")
+			}
+			return runBenchmark(cmd.Context(), cfg, benchmarkName, testSuiteName, repeat, dryRun, estimate, failFast, replayMode)
 		},
 	}
 	runCmd.Flags().String("test-suite", "", "Specify a test suite to run")
+	runCmd.Flags().Int("repeat", 0, "Run every test case N times (overrides the configured repetitions)")
+	runCmd.Flags().Bool("dry-run", false, "Load the benchmark without calling any provider")
+	runCmd.Flags().Bool("estimate", false, "Print the projected token usage and cost before running")
+	runCmd.Flags().Duration("timeout", 0, "Stop the run after this duration and report the completed test cases, e.g. 30m")
+	runCmd.Flags().Bool("fail-fast", false, "Abort the run on the first failed test case instead of reporting it")
+	runCmd.Flags().String("cache", "", "Response cache mode: read-write, read-only, refresh or off (overrides the benchmark config)")
+	runCmd.Flags().String("replay", "", "Record the provider responses as fixtures of the benchmark (record) or run offline from them (replay)")
+	runCmd.Flags().Duration("call-timeout", 0, "Cancel and retry a single provider call after this duration, e.g. 2m")
+
+	compareCmd := &cobra.Command{
+		Use:   "compare <baseline-run> <candidate-run>",
+		Short: "Compare two benchmark runs with paired significance tests",
+		Long:  "Compare two benchmark runs with paired significance tests. A run is given by its run ID or the path to its report.json.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return compareRuns(cfg, args[0], args[1])
+		},
+	}
+
+	rescoreCmd := &cobra.Command{
+		Use:   "rescore <run>",
+		Short: "Evaluate the outputs of a previous run again with the current metric configuration",
+		Long:  "Evaluate the outputs of a previous run again with the current metric configuration, without calling the models under test. A run is given by its run ID or the path to its report.json. The benchmark is the one the run was made with, unless --benchmark selects another.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			failFast, _ := cmd.Flags().GetBool("fail-fast")
+			cacheMode, _ := cmd.Flags().GetString("cache")
+			replayMode, _ := cmd.Flags().GetString("replay")
+			benchmark, _ := cmd.Flags().GetString("benchmark")
+			if cacheMode != "" {
+				cfg.SetFlag(config.KeyCache, cacheMode, "--cache")
+			}
+			return rescoreRun(cmd.Context(), cfg, args[0], benchmark, failFast, replayMode)
+		},
+	}
+	rescoreCmd.Flags().Bool("fail-fast", false, "Abort on the first failed evaluation instead of reporting it")
+	rescoreCmd.Flags().String("benchmark", "", "Name or path of the benchmark to score with (default: the benchmark of the run)")
+	rescoreCmd.Flags().String("cache", "", "Response cache mode: read-write, read-only, refresh or off (overrides the benchmark config)")
+	rescoreCmd.Flags().String("replay", "", "Record the provider responses as fixtures of the benchmark (record) or run offline from them (replay)")
+
+	validateCmd := &cobra.Command{
+		Use:   "validate <benchmark-name>",
+		Short: "Check the configs and files of a benchmark without running it",
+		Long:  "Check every config file (config.json or config.yaml) of a benchmark against its JSON Schema, the referenced input, expected and image files, the providers, models and metrics and report all problems at once.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return validateBenchmark(cfg, args[0])
+		},
+	}
+
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+
+	configShowCmd := &cobra.Command{
+		Use:   "show [benchmark-name]",
+		Short: "Print the effective settings and where they come from, with secrets redacted",
+		Long:  "Print the effective settings and where they come from, with secrets redacted. Settings are layered from the defaults, the global " + config.ConfigFileName + ", the benchmark config, the environment and the flags, in increasing priority.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			benchmarkName := ""
+			if len(args) > 0 {
+				benchmarkName = args[0]
+			}
+			return showConfig(cfg, benchmarkName)
+		},
+	}
+	configCmd.AddCommand(configShowCmd)
 
 	listCmd := &cobra.Command{
 		Use:   "list",
@@ -41,7 +146,7 @@ func NewRootCmd(cfg *config.Config) *cobra.Command {
 		Use:   "benchmarks",
 		Short: "List available benchmarks",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return listBenchmarks()
+			return listBenchmarks(cfg)
 		},
 	}
 
@@ -50,7 +155,7 @@ func NewRootCmd(cfg *config.Config) *cobra.Command {
 		Short: "List available test suites for a benchmark",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return listTestSuites(args[0])
+			return listTestSuites(cfg, args[0])
 		},
 	}
 
@@ -63,36 +168,222 @@ func NewRootCmd(cfg *config.Config) *cobra.Command {
 	}
 
 	listCmd.AddCommand(listBenchmarksCmd, listTestSuitesCmd, listProvidersCmd)
-	rootCmd.AddCommand(runCmd, listCmd)
+	rootCmd.AddCommand(runCmd, compareCmd, rescoreCmd, validateCmd, configCmd, listCmd)
 	return rootCmd
 }
 
-func runBenchmark(cfg *config.Config, benchmarkName, testSuiteName string) error {
-	benchConfigLoader, err := config.NewBenchmarkConfigLoader(benchmarkName)
+func runBenchmark(ctx context.Context, cfg *config.Config, benchmarkName, testSuiteName string, repeat int, dryRun, estimate, failFast bool, replayMode string) error {
+	benchConfig, err := loadBenchmarkConfig(cfg, benchmarkName, replayMode)
+	if err != nil {
+		return err
+	}
+	benchConfig.Repetitions = repeat
+	benchConfig.FailFast = failFast
+	service := services.NewBenchmarkService(benchConfig)
+
+	if estimate {
+		costEstimate, err := service.EstimateBenchmark(testSuiteName)
+		if err != nil {
+			return fmt.Errorf("error estimating benchmark cost: %v", err)
+		}
+		report.PrintCostEstimate(costEstimate)
+	}
+	if dryRun {
+		fmt.Println("Dry run, no test cases were executed.")
+		return nil
+	}
+
+	ctx, stop := withInterrupt(ctx)
+	defer stop()
+	return service.RunBenchmark(ctx, testSuiteName)
+}
+
+// setDurationFlag sets a timeout flag as a setting in whole seconds, rounded up. Zero leaves it unset.
+func setDurationFlag(cfg *config.Config, key string, duration time.Duration, flag string) {
+	if duration > 0 {
+		cfg.SetFlag(key, strconv.Itoa(int(math.Ceil(duration.Seconds()))), flag)
+	}
+}
+
+func loadBenchmarkConfig(cfg *config.Config, benchmarkName, replayMode string) (*domain.BenchmarkConfig, error) {
+	benchConfigLoader, err := config.NewBenchmarkConfigLoader(cfg.Paths, benchmarkName)
 	if err != nil {
-		return fmt.Errorf("error initiating the benchmark config loader: %v", err)
+		return nil, fmt.Errorf("error initiating the benchmark config loader: %v", err)
 	}
-	benchConfig, err := benchConfigLoader.LoadBenchmarkConfig(
-		cfg.EvalAPIKey,
-		cfg.EvalModel,
-		cfg.EvalProvider,
-		cfg.OpenAIAPIKey,
-	)
+	benchConfig, err := benchConfigLoader.LoadBenchmarkConfig(cfg)
 	if err != nil {
-		return fmt.Errorf("error loading benchmark config: %v", err)
+		return nil, fmt.Errorf("error loading benchmark config: %v", err)
 	}
-	service := services.NewBenchmarkService(benchConfig)
-	return service.RunBenchmark(testSuiteName)
+	benchConfig.ReplayMode, err = domain.ParseReplayMode(replayMode)
+	if err != nil {
+		return nil, err
+	}
+	if benchConfig.ReplayMode == domain.ReplayModeRecord && benchConfig.CacheMode != "" && benchConfig.CacheMode != domain.CacheModeOff {
+		fmt.Println("Note: the response cache is off while recording, every response is recorded as a fixture")
+	}
+	return benchConfig, nil
+}
+
+// withInterrupt cancels the context on Ctrl-C, so the run stops and the completed test cases are
+// still reported. A second Ctrl-C terminates immediately.
+func withInterrupt(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// rescoreRun scores a stored run again with the given benchmark or, by default, the benchmark the run
+// was made with.
+func rescoreRun(ctx context.Context, cfg *config.Config, run, benchmark string, failFast bool, replayMode string) error {
+	reportPath, err := findRunReport(cfg, run)
+	if err != nil {
+		return err
+	}
+	source, err := report.LoadBenchmarkReport(reportPath)
+	if err != nil {
+		return fmt.Errorf("error loading run: %v", err)
+	}
+
+	if benchmark == "" {
+		if source.BenchmarkDir == "" {
+			return fmt.Errorf("run %s does not name its benchmark, select it with --benchmark", source.RunID)
+		}
+		benchmark = source.BenchmarkDir
+	}
+	benchConfig, err := loadBenchmarkConfig(cfg, benchmark, replayMode)
+	if err != nil {
+		return err
+	}
+	benchConfig.FailFast = failFast
+
+	ctx, stop := withInterrupt(ctx)
+	defer stop()
+	return services.NewBenchmarkService(benchConfig).RescoreBenchmark(ctx, source)
+}
+
+func compareRuns(cfg *config.Config, baselineRun, candidateRun string) error {
+	baselinePath, err := findRunReport(cfg, baselineRun)
+	if err != nil {
+		return err
+	}
+	candidatePath, err := findRunReport(cfg, candidateRun)
+	if err != nil {
+		return err
+	}
+
+	baseline, err := report.LoadBenchmarkReport(baselinePath)
+	if err != nil {
+		return fmt.Errorf("error loading baseline run: %v", err)
+	}
+	candidate, err := report.LoadBenchmarkReport(candidatePath)
+	if err != nil {
+		return fmt.Errorf("error loading candidate run: %v", err)
+	}
+
+	comparison := domain.NewComparison(baseline, candidate)
+	outputDir := filepath.Join(filepath.Dir(candidatePath), "comparisons", baseline.RunID)
+	reports := []ports.ReportCreator{
+		report.NewStdoutReportCreator(),
+		report.NewJSONReportCreator(outputDir),
+		report.NewHTMLReportCreator(outputDir),
+	}
+	for _, r := range reports {
+		if err := r.GenerateComparisonReport(comparison); err != nil {
+			return fmt.Errorf("error generating comparison report: %v", err)
+		}
+	}
+	fmt.Printf("Comparison written to: %s\n", outputDir)
+	return nil
+}
+
+// findRunReport resolves a run ID, a run directory or a report file to the path of the run's report.json.
+func findRunReport(cfg *config.Config, run string) (string, error) {
+	if info, err := os.Stat(run); err == nil {
+		if info.IsDir() {
+			return filepath.Join(run, report.BenchmarkReportFile), nil
+		}
+		return run, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(cfg.Paths.Results, "*", run, report.BenchmarkReportFile))
+	if err != nil {
+		return "", fmt.Errorf("error searching for run %s: %v", run, err)
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("run not found: %s", run)
+	}
+	if len(matches) > 1 {
+		return "", fmt.Errorf("run ID %s is ambiguous, use the path to its report.json instead", run)
+	}
+	return matches[0], nil
 }
 
-func listBenchmarks() error {
-	benchmarksDir := filepath.Join("../", "../", "benchmarks")
-	entries, err := os.ReadDir(benchmarksDir)
+func validateBenchmark(cfg *config.Config, benchmarkName string) error {
+	loader, err := config.NewBenchmarkConfigLoader(cfg.Paths, benchmarkName)
 	if err != nil {
-		return fmt.Errorf("error reading benchmarks directory: %v", err)
+		return err
 	}
 
-	fmt.Println("Available benchmarks:")
+	problems, err := loader.Validate()
+	if err != nil {
+		return fmt.Errorf("error validating benchmark: %v", err)
+	}
+	if len(problems) == 0 {
+		fmt.Printf("Benchmark '%s' is valid\n", benchmarkName)
+		return nil
+	}
+
+	for _, problem := range problems {
+		fmt.Println("-", problem)
+	}
+	return fmt.Errorf("benchmark '%s' has %d problem(s)", benchmarkName, len(problems))
+}
+
+// showConfig prints the paths and the effective settings, with the benchmark config if a benchmark is given.
+func showConfig(cfg *config.Config, benchmarkName string) error {
+	settings := cfg.Settings(nil)
+	if benchmarkName != "" {
+		loader, err := config.NewBenchmarkConfigLoader(cfg.Paths, benchmarkName)
+		if err != nil {
+			return err
+		}
+		if settings, err = loader.Settings(cfg); err != nil {
+			return fmt.Errorf("error loading benchmark config: %v", err)
+		}
+	}
+
+	configFile := cfg.ConfigFile
+	if configFile == "" {
+		configFile = "(none)"
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Home:\t%s\n", cfg.Paths.Home)
+	fmt.Fprintf(w, "Benchmarks:\t%s\n", cfg.Paths.Benchmarks)
+	fmt.Fprintf(w, "Results:\t%s\n", cfg.Paths.Results)
+	fmt.Fprintf(w, "Cache:\t%s\n", cfg.Paths.Cache)
+	fmt.Fprintf(w, "Config file:\t%s\n", configFile)
+	w.Flush()
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Setting\tValue\tSource")
+	fmt.Fprintln(w, "-------\t-----\t------")
+	for _, setting := range settings.Sorted() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, setting.Redacted(), setting.Source)
+	}
+	return w.Flush()
+}
+
+func listBenchmarks(cfg *config.Config) error {
+	entries, err := os.ReadDir(cfg.Paths.Benchmarks)
+	if err != nil {
+		return fmt.Errorf("error reading benchmarks directory: %v (use --benchmarks-dir or set %s to select it)", err, config.HomeEnv)
+	}
+
+	fmt.Printf("Available benchmarks in %s:\n", cfg.Paths.Benchmarks)
 	for _, entry := range entries {
 		if entry.IsDir() {
 			fmt.Println("-", entry.Name())
@@ -101,8 +392,11 @@ func listBenchmarks() error {
 	return nil
 }
 
-func listTestSuites(benchmarkName string) error {
-	benchmarkDir := filepath.Join("../", "../", "benchmarks", benchmarkName)
+func listTestSuites(cfg *config.Config, benchmarkName string) error {
+	benchmarkDir, _, err := cfg.Paths.Benchmark(benchmarkName)
+	if err != nil {
+		return err
+	}
 	entries, err := os.ReadDir(benchmarkDir)
 	if err != nil {
 		return fmt.Errorf("error reading benchmark directory: %v", err)
@@ -110,7 +404,7 @@ func listTestSuites(benchmarkName string) error {
 
 	fmt.Printf("Test suites for benchmark '%s':\n", benchmarkName)
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if entry.IsDir() && entry.Name() != config.FixturesDir {
 			fmt.Println("-", entry.Name())
 		}
 	}
@@ -118,33 +412,27 @@ func listTestSuites(benchmarkName string) error {
 }
 
 func listProviders() error {
-	providerDir := filepath.Join("../", "../", "internal", "adapters", "llm")
-	entries, err := os.ReadDir(providerDir)
+	pricing, err := config.LoadPricingCatalog("")
 	if err != nil {
-		return fmt.Errorf("error reading providers directory: %v", err)
+		return fmt.Errorf("error loading pricing catalog: %v", err)
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight) // Align right
 	fmt.Fprintf(w, "%-10s\t%-20s\n", "Provider", "Model")                   //Fixed width for better alignment
 	fmt.Fprintf(w, "%-10s\t%-20s\n", "--------", "-----")
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			fileName := entry.Name()
-			providerName := strings.TrimSuffix(fileName, ".go")
-
-			var provider ports.LLMProvider
-			switch providerName {
-			case "openai":
-				provider = llm.NewOpenAIProvider("dummy-key", "dummy-model")
-			// Add cases for other providers as they are implemented
-			default:
-				continue
-			}
+	for _, providerName := range domain.KnownProviders {
+		var provider ports.LLMProvider
+		switch providerName {
+		case "openai":
+			provider = llm.NewOpenAIProvider("dummy-key", "dummy-model", pricing)
+		// Add cases for other providers as they are implemented
+		default:
+			continue
+		}
 
-			for _, model := range provider.GetModels() {
-				fmt.Fprintf(w, "%-10s\t%-20s\n", providerName, model) //Fixed width
-			}
+		for _, model := range provider.GetModels() {
+			fmt.Fprintf(w, "%-10s\t%-20s\n", providerName, model) //Fixed width
 		}
 	}
 
@@ -152,7 +440,7 @@ func listProviders() error {
 	return nil
 }
 
-func Execute(cfg *config.Config) error {
-	rootCmd := NewRootCmd(cfg)
+func Execute() error {
+	rootCmd := NewRootCmd()
 	return rootCmd.Execute()
 }
@@ -2,8 +2,8 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -11,6 +11,9 @@ import (
 	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
 )
 
+// FixturesDir is the directory in a benchmark that holds the recorded provider responses.
+const FixturesDir = "fixtures"
+
 type BenchmarkConfig struct {
 	Benchmark domain.Benchmark
 }
@@ -18,29 +21,29 @@ type BenchmarkConfig struct {
 type BenchmarkConfigLoader struct {
 	BasePath string
 	Name     string
+	paths    Paths
 }
 
-func NewBenchmarkConfigLoader(name string) (*BenchmarkConfigLoader, error) {
-	basePath := filepath.Join("../../", "benchmarks", name)
-	if _, err := os.Stat(basePath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("benchmark directory does not exist: %s", basePath)
+// NewBenchmarkConfigLoader creates a loader for a benchmark given by its name in the benchmarks
+// directory or by its path.
+func NewBenchmarkConfigLoader(paths Paths, benchmark string) (*BenchmarkConfigLoader, error) {
+	basePath, name, err := paths.Benchmark(benchmark)
+	if err != nil {
+		return nil, err
 	}
 	return &BenchmarkConfigLoader{
 		BasePath: basePath,
 		Name:     name,
+		paths:    paths,
 	}, nil
 }
 
-func (l *BenchmarkConfigLoader) LoadBenchmarkConfig(
-	evalAPIKey string,
-	evalModel string,
-	evalProvider string,
-	OpenAIAPIKey string,
-) (*domain.BenchmarkConfig, error) {
-	configPath := filepath.Join(l.BasePath, "config.json")
-	data, err := ioutil.ReadFile(configPath)
+// LoadBenchmarkConfig loads the benchmark with its test suites and applies the effective settings
+// of the config layers to it.
+func (l *BenchmarkConfigLoader) LoadBenchmarkConfig(cfg *Config) (*domain.BenchmarkConfig, error) {
+	data, configPath, err := readConfigFile(l.BasePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read benchmark config: %w", err)
+		return nil, fmt.Errorf("failed to read benchmark config %s: %w", configPath, err)
 	}
 
 	var benchmarkConfig domain.BenchmarkConfig
@@ -49,11 +52,26 @@ func (l *BenchmarkConfigLoader) LoadBenchmarkConfig(
 		return nil, fmt.Errorf("failed to unmarshal benchmark config: %w", err)
 	}
 
-	// Merge with default config
-	benchmarkConfig.EvalApiKey = evalAPIKey
-	benchmarkConfig.EvalModel = evalModel
-	benchmarkConfig.EvalProvider = evalProvider
-	benchmarkConfig.OpenAIAPIKey = OpenAIAPIKey
+	benchmarkSettings, err := benchmarkLayer(data, configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Settings(benchmarkSettings).apply(&benchmarkConfig); err != nil {
+		return nil, err
+	}
+	if benchmarkConfig.Dir, err = filepath.Abs(l.BasePath); err != nil {
+		return nil, fmt.Errorf("invalid benchmark directory %s: %w", l.BasePath, err)
+	}
+	benchmarkConfig.ResultsPath = filepath.Join(l.paths.Results, l.Name)
+	benchmarkConfig.CachePath = l.paths.Cache
+	benchmarkConfig.DiagramsPath = l.paths.Diagrams
+	benchmarkConfig.FixturesPath = filepath.Join(l.BasePath, FixturesDir)
+
+	pricing, err := LoadPricingCatalog(l.BasePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pricing catalog: %w", err)
+	}
+	benchmarkConfig.Pricing = pricing
 
 	// Load test suites
 	testSuites, err := l.loadTestSuites()
@@ -65,6 +83,28 @@ func (l *BenchmarkConfigLoader) LoadBenchmarkConfig(
 	return &benchmarkConfig, nil
 }
 
+// Settings returns the effective settings of the config layers and the benchmark config.
+func (l *BenchmarkConfigLoader) Settings(cfg *Config) (Settings, error) {
+	data, configPath, err := readConfigFile(l.BasePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read benchmark config %s: %w", configPath, err)
+	}
+	benchmarkSettings, err := benchmarkLayer(data, configPath)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Settings(benchmarkSettings), nil
+}
+
+// benchmarkLayer picks the settings from a benchmark config, its other fields are skipped.
+func benchmarkLayer(data []byte, configPath string) (Settings, error) {
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal benchmark config: %w", err)
+	}
+	return newLayer(values, SourceBenchmark+" "+configPath, false)
+}
+
 func (l *BenchmarkConfigLoader) loadTestSuites() ([]domain.TestSuiteConfig, error) {
 	var testSuites []domain.TestSuiteConfig
 
@@ -74,7 +114,7 @@ func (l *BenchmarkConfigLoader) loadTestSuites() ([]domain.TestSuiteConfig, erro
 	}
 
 	for _, suiteDir := range suiteDirs {
-		if suiteDir.IsDir() {
+		if suiteDir.IsDir() && suiteDir.Name() != FixturesDir {
 			suite, err := l.loadTestSuite(suiteDir.Name())
 			if err != nil {
 				return nil, fmt.Errorf("failed to load test suite %s: %w", suiteDir.Name(), err)
@@ -88,11 +128,9 @@ func (l *BenchmarkConfigLoader) loadTestSuites() ([]domain.TestSuiteConfig, erro
 
 func (l *BenchmarkConfigLoader) loadTestSuite(suiteName string) (domain.TestSuiteConfig, error) {
 	suitePath := filepath.Join(l.BasePath, suiteName)
-	configPath := filepath.Join(suitePath, "config.json")
-
-	data, err := ioutil.ReadFile(configPath)
+	data, configPath, err := readConfigFile(suitePath)
 	if err != nil {
-		return domain.TestSuiteConfig{}, fmt.Errorf("failed to read test suite config: %w", err)
+		return domain.TestSuiteConfig{}, fmt.Errorf("failed to read test suite config %s: %w", configPath, err)
 	}
 
 	var suite domain.TestSuiteConfig
@@ -102,12 +140,33 @@ func (l *BenchmarkConfigLoader) loadTestSuite(suiteName string) (domain.TestSuit
 	}
 
 	suite.Name = suiteName
+	suite.Path = suitePath
+	if suite.Diagrams.Mode, err = domain.ParseDiagramMode(string(suite.Diagrams.Mode)); err != nil {
+		return domain.TestSuiteConfig{}, err
+	}
+	if format := suite.ImageProcessing.Format; format != "" && format != domain.ImageFormatPNG && format != domain.ImageFormatJPEG {
+		return domain.TestSuiteConfig{}, fmt.Errorf("unknown image format %q, expected png or jpeg", format)
+	}
 
 	// Load test cases
 	testCases, err := l.loadTestCases(suitePath)
 	if err != nil {
 		return domain.TestSuiteConfig{}, fmt.Errorf("failed to load test cases: %w", err)
 	}
+	if suite.Cases != "" {
+		datasetCases, err := loadDataset(filepath.Join(suitePath, suite.Cases))
+		if err != nil {
+			return domain.TestSuiteConfig{}, fmt.Errorf("failed to load test cases: %w", err)
+		}
+		testCases = append(testCases, datasetCases...)
+	}
+	seen := map[string]bool{}
+	for _, testCase := range testCases {
+		if seen[testCase.Name] {
+			return domain.TestSuiteConfig{}, fmt.Errorf("duplicate test case %s", testCase.Name)
+		}
+		seen[testCase.Name] = true
+	}
 	suite.TestCaseConfigs = testCases
 
 	return suite, nil
@@ -137,44 +196,103 @@ func (l *BenchmarkConfigLoader) loadTestCases(suitePath string) ([]domain.TestCa
 func (l *BenchmarkConfigLoader) loadTestCase(suitePath, caseName string) (domain.TestCaseConfig, error) {
 	casePath := filepath.Join(suitePath, caseName)
 
-	// load and parse the config.json in the test case folder
-	configPath := filepath.Join(casePath, "config.json")
-	configFile, err := os.Open(configPath)
+	// load and parse the config file in the test case folder
+	configData, configPath, err := readConfigFile(casePath)
 	if err != nil {
-		return domain.TestCaseConfig{}, fmt.Errorf("failed to open config.json: %w", err)
+		return domain.TestCaseConfig{}, fmt.Errorf("failed to read %s: %w", configPath, err)
 	}
-	defer configFile.Close()
 
-	configData, err := io.ReadAll(configFile)
+	var config testCaseFile
+	if err := json.Unmarshal(configData, &config); err != nil {
+		return domain.TestCaseConfig{}, fmt.Errorf("failed to parse %s: %w", configPath, err)
+	}
+
+	input, err := config.input(casePath)
 	if err != nil {
-		return domain.TestCaseConfig{}, fmt.Errorf("failed to read config.json: %w", err)
+		return domain.TestCaseConfig{}, err
+	}
+	scoreTurns, err := domain.ParseScoreTurns(string(config.ScoreTurns))
+	if err != nil {
+		return domain.TestCaseConfig{}, fmt.Errorf("invalid %s: %w", configPath, err)
+	}
+	if config.Tools != nil && len(config.Conversation) > 0 {
+		return domain.TestCaseConfig{}, fmt.Errorf("invalid %s: %w", configPath, errToolsInConversation)
+	}
+	expected, err := config.expected(casePath)
+	if err != nil {
+		return domain.TestCaseConfig{}, err
 	}
 
-	var config = domain.TestCaseConfig{}
-	if err := json.Unmarshal(configData, &config); err != nil {
-		return domain.TestCaseConfig{}, fmt.Errorf("failed to parse config.json: %w", err)
+	return domain.TestCaseConfig{
+		Name:          caseName,
+		Path:          casePath,
+		Input:         input,
+		Expected:      expected,
+		Images:        config.Images,
+		Documents:     config.Documents,
+		Diagrams:      config.Diagrams,
+		Repetitions:   config.Repetitions,
+		Tags:          config.Tags,
+		Generation:    config.Generation,
+		MetricConfigs: config.MetricConfigs,
+		Mock:          config.Mock,
+		Conversation:  config.Conversation,
+		ScoreTurns:    scoreTurns,
+		Tools:         config.Tools,
+		Repository:    config.Repository,
+	}, nil
+}
+
+// errToolsInConversation rejects test cases that combine tools with a conversation.
+var errToolsInConversation = errors.New("tools can not be used in a conversation")
+
+// testCaseFile is the config file of a test case. The input and the expected output are given as
+// file names or inline, e.g. as YAML block scalars. A conversation replaces the input.
+type testCaseFile struct {
+	domain.TestCaseConfig
+	InputText    string `json:"input_text"`
+	ExpectedText string `json:"expected_text"`
+}
+
+// conversationExpected reports whether a turn of the conversation has an expected answer.
+func (f *testCaseFile) conversationExpected() bool {
+	for _, turn := range f.Conversation {
+		if turn.Expected != "" {
+			return true
+		}
 	}
+	return false
+}
 
+func (f *testCaseFile) input(casePath string) (string, error) {
+	if f.InputText != "" {
+		return f.InputText, nil
+	}
+	if f.Input == "" && len(f.Conversation) > 0 {
+		// The conversation replaces the input
+		return "", nil
+	}
 	// Construct full paths based on config values, not hardcoded filenames
-	inputPath := filepath.Join(casePath, config.Input)
-	inputBytes, err := ioutil.ReadFile(inputPath)
+	inputPath := filepath.Join(casePath, f.Input)
+	inputBytes, err := os.ReadFile(inputPath)
 	if err != nil {
-		return domain.TestCaseConfig{}, fmt.Errorf("failed to read input file '%s': %w", inputPath, err)
+		return "", fmt.Errorf("failed to read input file '%s': %w", inputPath, err)
 	}
+	return string(inputBytes), nil
+}
 
-	expectedOutputPath := filepath.Join(casePath, config.Expected)
-	expectedOutputBytes, err := ioutil.ReadFile(expectedOutputPath)
-	if err != nil {
-		return domain.TestCaseConfig{}, fmt.Errorf("failed to read expected output file '%s': %w", expectedOutputPath, err)
+func (f *testCaseFile) expected(casePath string) (string, error) {
+	if f.ExpectedText != "" {
+		return f.ExpectedText, nil
 	}
-
-	config = domain.TestCaseConfig{
-		Name:     caseName,
-		Path:     casePath,
-		Input:    string(inputBytes),
-		Expected: string(expectedOutputBytes),
-		Images:   config.Images,
+	if f.Expected == "" && f.conversationExpected() {
+		// The turns of the conversation carry the expected answers
+		return "", nil
 	}
-
-	return config, nil
+	expectedOutputPath := filepath.Join(casePath, f.Expected)
+	expectedOutputBytes, err := os.ReadFile(expectedOutputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read expected output file '%s': %w", expectedOutputPath, err)
+	}
+	return string(expectedOutputBytes), nil
 }
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+// TestConversationExpected checks that a conversation whose turns carry the expected answers needs
+// no expected file, and that a test case without either still does.
+func TestConversationExpected(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		wantErr     bool
+		wantProblem bool
+	}{
+		{
+			name:   "expected in the last turn",
+			config: `{"conversation": [{"role": "user", "content": "Design it.", "expected": "A design."}]}`,
+		},
+		{
+			name:        "no expected answer",
+			config:      `{"conversation": [{"role": "user", "content": "Design it."}]}`,
+			wantErr:     true,
+			wantProblem: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suitePath := t.TempDir()
+			casePath := filepath.Join(suitePath, "case")
+			if err := os.MkdirAll(casePath, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(casePath, "config.json"), []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			testCase, err := (&BenchmarkConfigLoader{}).loadTestCase(suitePath, "case")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("load error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && testCase.Expected != "" {
+				t.Errorf("expected = %q, want none", testCase.Expected)
+			}
+
+			schemas, err := loadSchemas()
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := &benchmarkValidator{schemas: schemas}
+			v.validateTestCase(casePath, &domain.TestSuiteConfig{})
+			if (len(v.problems) > 0) != tt.wantProblem {
+				t.Errorf("problems = %+v, want problems %v", v.problems, tt.wantProblem)
+			}
+		})
+	}
+}
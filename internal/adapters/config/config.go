@@ -1,31 +1,110 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// ConfigFileName is the name of the global config file, looked up in the home directory and in the
+// user config directory.
+const ConfigFileName = "arch-bench.yaml"
+
+// Config holds the settings layers around the benchmark config: the defaults and the global config
+// file below it, the environment and the flags above it.
 type Config struct {
-	EvalAPIKey   string
-	EvalModel    string
-	EvalProvider string
-	// connection keys for non eval models
-	OpenAIAPIKey string
+	Paths Paths
+	// ConfigFile is the path of the loaded global config file, empty if there is none
+	ConfigFile string
+	defaults   Settings
+	file       Settings
+	env        Settings
+	flags      Settings
 }
 
-func LoadConfig() (*Config, error) {
-	pwd, _ := os.Getwd()
-	err := godotenv.Load(filepath.Join(pwd, "../../.env"))
+// LoadConfig resolves the paths (see ResolvePaths), loads the .env file of the home directory
+// into the environment and reads the settings layers. configFile selects the global config file
+// instead of the lookup, setFlags are the key=value pairs of --set.
+func LoadConfig(benchmarksDir, configFile string, setFlags []string) (*Config, error) {
+	paths, err := ResolvePaths(benchmarksDir)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Config{
-		EvalAPIKey:   os.Getenv("EVAL_API_KEY"),
-		EvalModel:    os.Getenv("EVAL_MODEL"),
-		EvalProvider: os.Getenv("EVAL_PROVIDER"),
-		OpenAIAPIKey: os.Getenv("OPENAI_API_KEY"),
-	}, nil
+	// The .env file is optional, e.g. for offline runs from fixtures or when the variables are set in the environment
+	err = godotenv.Load(filepath.Join(paths.Home, ".env"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	cfg := &Config{Paths: paths, defaults: defaultLayer(), file: Settings{}}
+	if configFile == "" {
+		configFile = findConfigFile(paths.Home)
+	}
+	if configFile != "" {
+		if cfg.file, err = loadConfigFile(configFile); err != nil {
+			return nil, err
+		}
+		cfg.ConfigFile = configFile
+	}
+
+	providerNames := slices.Clone(domain.KnownProviders)
+	for providerName := range cfg.file.Providers() {
+		if !slices.Contains(providerNames, providerName) {
+			providerNames = append(providerNames, providerName)
+		}
+	}
+	cfg.env = envLayer(providerNames)
+
+	if cfg.flags, err = parseSetFlags(setFlags); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// SetFlag sets a setting from a dedicated flag, e.g. --cache, with the priority of --set.
+func (c *Config) SetFlag(key, value, flag string) {
+	c.flags[key] = Setting{Key: key, Value: value, Source: SourceFlag + " " + flag}
+}
+
+// Settings returns the effective settings with the given benchmark layer, which may be nil.
+func (c *Config) Settings(benchmark Settings) Settings {
+	settings := Settings{}
+	for _, layer := range []Settings{c.defaults, c.file, benchmark, c.env, c.flags} {
+		settings.merge(layer)
+	}
+	return settings
+}
+
+// findConfigFile returns the global config file in the home directory or the user config
+// directory, or "" if there is none.
+func findConfigFile(home string) string {
+	candidates := []string{filepath.Join(home, ConfigFileName)}
+	if userConfigDir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(userConfigDir, "arch-bench", ConfigFileName))
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+func loadConfigFile(path string) (Settings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return newLayer(values, SourceFile+" "+path, true)
 }
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames are the names of the config file of a benchmark, test suite or test case.
+var configFileNames = []string{"config.json", "config.yaml", "config.yml"}
+
+// readConfigFile reads the config file in dir and returns it as JSON, so YAML configs are decoded
+// and validated like JSON configs, together with its path. A directory must not hold more than
+// one config file.
+func readConfigFile(dir string) ([]byte, string, error) {
+	var path string
+	for _, fileName := range configFileNames {
+		candidate := filepath.Join(dir, fileName)
+		if _, err := os.Stat(candidate); err != nil {
+			continue
+		}
+		if path != "" {
+			return nil, candidate, fmt.Errorf("both %s and %s exist, keep only one of them", filepath.Base(path), fileName)
+		}
+		path = candidate
+	}
+	if path == "" {
+		return nil, filepath.Join(dir, configFileNames[0]), fmt.Errorf("no config file found, expected one of %s", strings.Join(configFileNames, ", "))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, path, err
+	}
+	if filepath.Ext(path) == ".json" {
+		return data, path, nil
+	}
+
+	var value any
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, path, fmt.Errorf("invalid YAML: %w", err)
+	}
+	if value == nil {
+		value = map[string]any{}
+	}
+	data, err = json.Marshal(value)
+	if err != nil {
+		return nil, path, fmt.Errorf("failed to convert YAML to JSON: %w", err)
+	}
+	return data, path, nil
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+// datasetCase is a test case in a dataset file, a JSONL or CSV file with one test case per line.
+// Unlike in the config file of a test case directory the input and expected output are inline.
+type datasetCase struct {
+	Name        string                  `json:"name"`
+	Input       string                  `json:"input"`
+	Expected    string                  `json:"expected"`
+	Images      []string                `json:"images"`
+	Documents   []string                `json:"documents"`
+	Diagrams    []string                `json:"diagrams"`
+	Tags        []string                `json:"tags"`
+	Repetitions int                     `json:"repetitions"`
+	Generation  domain.GenerationConfig `json:"generation"`
+	Metrics     []domain.MetricConfig   `json:"metrics"`
+	Mock        *domain.MockConfig      `json:"mock,omitempty"`
+	// Conversation, ScoreTurns, Tools and Repository can only be given in JSONL files
+	Conversation []domain.Turn            `json:"conversation"`
+	ScoreTurns   domain.ScoreTurns        `json:"score_turns"`
+	Tools        *domain.ToolConfig       `json:"tools,omitempty"`
+	Repository   *domain.RepositoryConfig `json:"repository,omitempty"`
+}
+
+// datasetRecord is a line of a dataset file as JSON.
+type datasetRecord struct {
+	Line int
+	Data []byte
+}
+
+// datasetColumns are the columns of a CSV dataset. Images, documents, diagrams, tags and metrics hold
+// lists separated by semicolons, a metric is given as name or name:weight.
+var datasetColumns = []string{"name", "input", "expected", "images", "documents", "diagrams", "tags", "repetitions", "metrics"}
+
+// loadDataset loads the test cases of a dataset file. Images, documents, diagrams, fixtures and repositories are relative
+// to the directory of the file.
+func loadDataset(path string) ([]domain.TestCaseConfig, error) {
+	records, err := readDataset(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var testCases []domain.TestCaseConfig
+	for _, record := range records {
+		var testCase datasetCase
+		if err := json.Unmarshal(record.Data, &testCase); err != nil {
+			return nil, fmt.Errorf("failed to parse %s line %d: %w", path, record.Line, err)
+		}
+		if testCase.Name == "" {
+			return nil, fmt.Errorf("test case without name in %s line %d", path, record.Line)
+		}
+		if testCase.ScoreTurns, err = domain.ParseScoreTurns(string(testCase.ScoreTurns)); err != nil {
+			return nil, fmt.Errorf("invalid %s line %d: %w", path, record.Line, err)
+		}
+		if testCase.Tools != nil && len(testCase.Conversation) > 0 {
+			return nil, fmt.Errorf("invalid %s line %d: %w", path, record.Line, errToolsInConversation)
+		}
+		testCases = append(testCases, testCase.testCaseConfig(filepath.Dir(path)))
+	}
+	return testCases, nil
+}
+
+func (c datasetCase) testCaseConfig(dir string) domain.TestCaseConfig {
+	return domain.TestCaseConfig{
+		Name:          c.Name,
+		Path:          dir,
+		Input:         c.Input,
+		Expected:      c.Expected,
+		Images:        c.Images,
+		Documents:     c.Documents,
+		Diagrams:      c.Diagrams,
+		Repetitions:   c.Repetitions,
+		Tags:          c.Tags,
+		Generation:    c.Generation,
+		MetricConfigs: c.Metrics,
+		Mock:          c.Mock,
+		Conversation:  c.Conversation,
+		ScoreTurns:    c.ScoreTurns,
+		Tools:         c.Tools,
+		Repository:    c.Repository,
+	}
+}
+
+// readDataset reads the records of a .jsonl or .csv dataset file, blank lines are skipped.
+func readDataset(path string) ([]datasetRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl":
+		return readJSONLDataset(file)
+	case ".csv":
+		return readCSVDataset(file)
+	default:
+		return nil, fmt.Errorf("unsupported dataset format %s, use .jsonl or .csv", path)
+	}
+}
+
+func readJSONLDataset(r io.Reader) ([]datasetRecord, error) {
+	var records []datasetRecord
+	scanner := bufio.NewScanner(r)
+	// Long prompts easily exceed the default line limit of 64 KB
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		records = append(records, datasetRecord{Line: line, Data: bytes.Clone(data)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+	return records, nil
+}
+
+func readCSVDataset(r io.Reader) ([]datasetRecord, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CSV header: %w", err)
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if !slices.Contains(datasetColumns, header[i]) {
+			return nil, fmt.Errorf("unknown CSV column %q, known columns are %s", column, strings.Join(datasetColumns, ", "))
+		}
+	}
+
+	var records []datasetRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dataset: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		values, err := csvRowValues(header, row)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		data, err := json.Marshal(values)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, datasetRecord{Line: line, Data: data})
+	}
+	return records, nil
+}
+
+// csvRowValues converts a CSV row to the fields of a datasetCase, empty cells are left out.
+func csvRowValues(header, row []string) (map[string]any, error) {
+	values := map[string]any{}
+	for i, cell := range row {
+		if cell == "" {
+			continue
+		}
+		switch column := header[i]; column {
+		case "images", "documents", "diagrams", "tags":
+			values[column] = splitList(cell)
+		case "repetitions":
+			repetitions, err := strconv.Atoi(strings.TrimSpace(cell))
+			if err != nil {
+				return nil, fmt.Errorf("invalid repetitions %q", cell)
+			}
+			values[column] = repetitions
+		case "metrics":
+			var metrics []map[string]any
+			for _, metric := range splitList(cell) {
+				name, weight, hasWeight := strings.Cut(metric, ":")
+				value := map[string]any{"name": strings.TrimSpace(name)}
+				if hasWeight {
+					parsed, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+					if err != nil {
+						return nil, fmt.Errorf("invalid weight of metric %q", metric)
+					}
+					value["weight"] = parsed
+				}
+				metrics = append(metrics, value)
+			}
+			values[column] = metrics
+		default:
+			values[column] = cell
+		}
+	}
+	return values, nil
+}
+
+func splitList(cell string) []string {
+	var items []string
+	for _, item := range strings.Split(cell, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
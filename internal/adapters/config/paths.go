@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HomeEnv names the environment variable of the arch-bench home directory, the directory that
+// holds the benchmarks, the results, the response cache and the .env file.
+const HomeEnv = "ARCH_BENCH_HOME"
+
+// BenchmarksDirName is the name of the benchmarks directory in the home directory.
+const BenchmarksDirName = "benchmarks"
+
+// Paths are the directories arch-bench reads from and writes to.
+type Paths struct {
+	Home string
+	// Benchmarks is the directory with one folder per benchmark
+	Benchmarks string
+	// Results is the directory the benchmark runs are written to, one folder per benchmark and run
+	Results string
+	// Cache is the directory of the response cache, shared by all benchmarks
+	Cache string
+	// Diagrams is the directory of the rendered diagrams, shared by all benchmarks
+	Diagrams string
+}
+
+// ResolvePaths discovers the benchmarks directory, in this order: the given directory (e.g. from
+// --benchmarks-dir), $ARCH_BENCH_HOME/benchmarks, a benchmarks directory in the working directory
+// or one of its parents, and one next to the binary or in one of its parents. The parent of the
+// benchmarks directory is the home directory. Without a benchmarks directory the working directory
+// is used as home.
+func ResolvePaths(benchmarksDir string) (Paths, error) {
+	var home string
+	switch {
+	case benchmarksDir != "":
+		dir, err := filepath.Abs(benchmarksDir)
+		if err != nil {
+			return Paths{}, fmt.Errorf("invalid benchmarks directory %s: %w", benchmarksDir, err)
+		}
+		if !isDir(dir) {
+			return Paths{}, fmt.Errorf("benchmarks directory does not exist: %s", dir)
+		}
+		if envHome := os.Getenv(HomeEnv); envHome != "" {
+			home = envHome
+		} else {
+			home = filepath.Dir(dir)
+		}
+		return newPaths(home, dir)
+	case os.Getenv(HomeEnv) != "":
+		home = os.Getenv(HomeEnv)
+		if !isDir(home) {
+			return Paths{}, fmt.Errorf("%s does not exist: %s", HomeEnv, home)
+		}
+	default:
+		pwd, err := os.Getwd()
+		if err != nil {
+			return Paths{}, fmt.Errorf("failed to get the working directory: %w", err)
+		}
+		home = findHome(pwd)
+		if home == "" {
+			if executable, err := os.Executable(); err == nil {
+				home = findHome(filepath.Dir(executable))
+			}
+		}
+		if home == "" {
+			home = pwd
+		}
+	}
+	return newPaths(home, "")
+}
+
+// Benchmark resolves a benchmark given by its name in the benchmarks directory or by its path
+// and returns the benchmark directory and name.
+func (p Paths) Benchmark(benchmark string) (string, string, error) {
+	path := filepath.Join(p.Benchmarks, benchmark)
+	if filepath.IsAbs(benchmark) || strings.ContainsRune(benchmark, filepath.Separator) {
+		path = filepath.Clean(benchmark)
+	}
+	if !isDir(path) {
+		return "", "", fmt.Errorf("benchmark directory does not exist: %s (use --benchmarks-dir or set %s to select the benchmarks directory)", path, HomeEnv)
+	}
+	return path, filepath.Base(path), nil
+}
+
+// newPaths returns the paths in the home directory, with the given or the default benchmarks directory.
+func newPaths(home, benchmarksDir string) (Paths, error) {
+	home, err := filepath.Abs(home)
+	if err != nil {
+		return Paths{}, fmt.Errorf("invalid home directory %s: %w", home, err)
+	}
+	if benchmarksDir == "" {
+		benchmarksDir = filepath.Join(home, BenchmarksDirName)
+	}
+	return Paths{
+		Home:       home,
+		Benchmarks: benchmarksDir,
+		Results:    filepath.Join(home, "results"),
+		Cache:      filepath.Join(home, ".cache", "responses"),
+		Diagrams:   filepath.Join(home, ".cache", "diagrams"),
+	}, nil
+}
+
+// findHome returns the first of dir and its parents that holds a benchmarks directory, or "".
+func findHome(dir string) string {
+	for {
+		if isDir(filepath.Join(dir, BenchmarksDirName)) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
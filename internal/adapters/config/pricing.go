@@ -0,0 +1,74 @@
+package config
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed pricing.yaml
+var defaultPricing []byte
+
+// pricingOverrideFiles are looked up in the benchmark directory, in this order.
+var pricingOverrideFiles = []string{"pricing.yaml", "pricing.yml", "pricing.json"}
+
+// LoadPricingCatalog loads the built-in pricing catalog and merges the override of the benchmark
+// directory into it, if there is one. An empty benchmark path returns the built-in catalog.
+func LoadPricingCatalog(benchmarkPath string) (*domain.PricingCatalog, error) {
+	catalog, err := parsePricingCatalog(defaultPricing, "pricing.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse built-in pricing catalog: %w", err)
+	}
+	if benchmarkPath == "" {
+		return catalog, nil
+	}
+
+	for _, fileName := range pricingOverrideFiles {
+		path := filepath.Join(benchmarkPath, fileName)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pricing catalog %s: %w", path, err)
+		}
+
+		override, err := parsePricingCatalog(data, fileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse pricing catalog %s: %w", path, err)
+		}
+		catalog = catalog.Merge(override)
+	}
+
+	return catalog, nil
+}
+
+func parsePricingCatalog(data []byte, fileName string) (*domain.PricingCatalog, error) {
+	var catalog domain.PricingCatalog
+	var err error
+	if strings.HasSuffix(fileName, ".json") {
+		err = json.Unmarshal(data, &catalog)
+	} else {
+		err = yaml.Unmarshal(data, &catalog)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, price := range catalog.Prices {
+		if price.Provider == "" || price.Model == "" {
+			return nil, fmt.Errorf("price entry without provider or model: %+v", price)
+		}
+		if _, err := time.Parse(domain.PriceDateFormat, price.EffectiveDate); err != nil {
+			return nil, fmt.Errorf("invalid effective date for %s/%s: %w", price.Provider, price.Model, err)
+		}
+	}
+	return &catalog, nil
+}
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+// Keys of the layered settings, as used in arch-bench.yaml, the benchmark config.json and --set.
+// Provider credentials use providers.<provider>.api_key and providers.<provider>.base_url.
+const (
+	KeyEvalProvider       = "eval_provider"
+	KeyEvalModel          = "eval_model"
+	KeyEvalAPIKey         = "eval_api_key"
+	KeyMaxCost            = "max_cost"
+	KeyCallTimeoutSeconds = "call_timeout_seconds"
+	KeyRunTimeoutSeconds  = "run_timeout_seconds"
+	KeyCache              = "cache"
+)
+
+// Sources of the settings, in increasing priority.
+const (
+	SourceDefault   = "default"
+	SourceFile      = "file"
+	SourceBenchmark = "benchmark"
+	SourceEnv       = "env"
+	SourceFlag      = "flag"
+)
+
+var settingKeys = []string{KeyEvalProvider, KeyEvalModel, KeyEvalAPIKey, KeyMaxCost, KeyCallTimeoutSeconds, KeyRunTimeoutSeconds, KeyCache}
+
+// settingEnvVars maps the environment variables to the settings, besides the provider credentials.
+var settingEnvVars = map[string]string{
+	"EVAL_PROVIDER":                   KeyEvalProvider,
+	"EVAL_MODEL":                      KeyEvalModel,
+	"EVAL_API_KEY":                    KeyEvalAPIKey,
+	"ARCH_BENCH_MAX_COST":             KeyMaxCost,
+	"ARCH_BENCH_CALL_TIMEOUT_SECONDS": KeyCallTimeoutSeconds,
+	"ARCH_BENCH_RUN_TIMEOUT_SECONDS":  KeyRunTimeoutSeconds,
+	"ARCH_BENCH_CACHE":                KeyCache,
+}
+
+var defaultSettings = map[string]string{
+	KeyEvalProvider: "openai",
+	KeyEvalModel:    "gpt-4o-mini",
+}
+
+// Setting is the value of a setting and where it comes from, e.g. "env OPENAI_API_KEY".
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Secret reports whether the setting holds a credential that must not be printed.
+func (s Setting) Secret() bool {
+	return strings.HasSuffix(s.Key, "api_key")
+}
+
+// Redacted returns the value with secrets masked, only the last characters of long keys are kept.
+func (s Setting) Redacted() string {
+	if !s.Secret() || s.Value == "" {
+		return s.Value
+	}
+	if len(s.Value) < 12 {
+		return "****"
+	}
+	return "****" + s.Value[len(s.Value)-4:]
+}
+
+// Settings holds the effective value of every setting that is set.
+type Settings map[string]Setting
+
+// merge sets the settings of a layer, overriding the values of the lower layers.
+func (s Settings) merge(layer Settings) {
+	for key, setting := range layer {
+		s[key] = setting
+	}
+}
+
+// Value returns the value of a setting or "" if it is not set.
+func (s Settings) Value(key string) string {
+	return s[key].Value
+}
+
+// Sorted returns the settings sorted by key.
+func (s Settings) Sorted() []Setting {
+	var sorted []Setting
+	for _, setting := range s {
+		sorted = append(sorted, setting)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	return sorted
+}
+
+// Providers returns the credentials of all providers in the settings.
+func (s Settings) Providers() map[string]domain.ProviderConfig {
+	providers := map[string]domain.ProviderConfig{}
+	for key, setting := range s {
+		providerName, field, ok := providerKey(key)
+		if !ok {
+			continue
+		}
+		provider := providers[providerName]
+		if field == "api_key" {
+			provider.APIKey = setting.Value
+		} else {
+			provider.BaseURL = setting.Value
+		}
+		providers[providerName] = provider
+	}
+	return providers
+}
+
+// apply sets the effective settings in the benchmark config.
+func (s Settings) apply(cfg *domain.BenchmarkConfig) error {
+	cfg.EvalProvider = s.Value(KeyEvalProvider)
+	cfg.EvalModel = s.Value(KeyEvalModel)
+	cfg.EvalApiKey = s.Value(KeyEvalAPIKey)
+	cfg.Providers = s.Providers()
+
+	var err error
+	if cfg.MaxCost, err = s.float(KeyMaxCost); err != nil {
+		return err
+	}
+	if cfg.CallTimeoutSeconds, err = s.int(KeyCallTimeoutSeconds); err != nil {
+		return err
+	}
+	if cfg.RunTimeoutSeconds, err = s.int(KeyRunTimeoutSeconds); err != nil {
+		return err
+	}
+	if cfg.CacheMode, err = domain.ParseCacheMode(s.Value(KeyCache)); err != nil {
+		return fmt.Errorf("%v (%s)", err, s[KeyCache].Source)
+	}
+	return nil
+}
+
+func (s Settings) float(key string) (float64, error) {
+	setting, ok := s[key]
+	if !ok || setting.Value == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseFloat(setting.Value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q (%s): expected a number", key, setting.Value, setting.Source)
+	}
+	return value, nil
+}
+
+func (s Settings) int(key string) (int, error) {
+	setting, ok := s[key]
+	if !ok || setting.Value == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(setting.Value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q (%s): expected a whole number", key, setting.Value, setting.Source)
+	}
+	return value, nil
+}
+
+// providerKey splits providers.<provider>.<field> into the provider and the field.
+func providerKey(key string) (string, string, bool) {
+	parts := strings.Split(key, ".")
+	if len(parts) != 3 || parts[0] != "providers" || parts[1] == "" {
+		return "", "", false
+	}
+	if parts[2] != "api_key" && parts[2] != "base_url" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// IsSettingKey reports whether key names a setting.
+func IsSettingKey(key string) bool {
+	for _, settingKey := range settingKeys {
+		if key == settingKey {
+			return true
+		}
+	}
+	_, _, ok := providerKey(key)
+	return ok
+}
+
+// newLayer creates a layer from the flattened values of a config file. Keys that are not settings
+// are an error in strict mode and skipped otherwise, e.g. the other fields of a benchmark config.
+func newLayer(values map[string]any, source string, strict bool) (Settings, error) {
+	layer := Settings{}
+	for key, value := range flatten("", values) {
+		if !IsSettingKey(key) {
+			if strict {
+				return nil, fmt.Errorf("unknown setting %s in %s", key, source)
+			}
+			continue
+		}
+		layer[key] = Setting{Key: key, Value: value, Source: source}
+	}
+	return layer, nil
+}
+
+// flatten joins the keys of nested maps with dots and formats the values as strings.
+func flatten(prefix string, values map[string]any) map[string]string {
+	flat := map[string]string{}
+	for key, value := range values {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch value := value.(type) {
+		case map[string]any:
+			for nestedKey, nestedValue := range flatten(key, value) {
+				flat[nestedKey] = nestedValue
+			}
+		case float64:
+			flat[key] = strconv.FormatFloat(value, 'f', -1, 64)
+		case nil:
+		default:
+			flat[key] = fmt.Sprint(value)
+		}
+	}
+	return flat
+}
+
+func defaultLayer() Settings {
+	layer := Settings{}
+	for key, value := range defaultSettings {
+		layer[key] = Setting{Key: key, Value: value, Source: SourceDefault}
+	}
+	return layer
+}
+
+// envLayer reads the settings from the environment. Credentials are read as <PROVIDER>_API_KEY and
+// <PROVIDER>_BASE_URL for the known providers and every provider that is configured in a lower layer.
+func envLayer(providerNames []string) Settings {
+	layer := Settings{}
+	for envVar, key := range settingEnvVars {
+		if value, ok := os.LookupEnv(envVar); ok {
+			layer[key] = Setting{Key: key, Value: value, Source: SourceEnv + " " + envVar}
+		}
+	}
+	for _, providerName := range providerNames {
+		prefix := strings.ToUpper(strings.ReplaceAll(providerName, "-", "_"))
+		for envSuffix, field := range map[string]string{"_API_KEY": "api_key", "_BASE_URL": "base_url"} {
+			envVar := prefix + envSuffix
+			if value, ok := os.LookupEnv(envVar); ok {
+				key := "providers." + providerName + "." + field
+				layer[key] = Setting{Key: key, Value: value, Source: SourceEnv + " " + envVar}
+			}
+		}
+	}
+	return layer
+}
+
+// parseSetFlags creates the flag layer from key=value pairs.
+func parseSetFlags(pairs []string) (Settings, error) {
+	layer := Settings{}
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --set %q, expected key=value", pair)
+		}
+		if !IsSettingKey(key) {
+			return nil, fmt.Errorf("unknown setting %s in --set", key)
+		}
+		layer[key] = Setting{Key: key, Value: value, Source: SourceFlag + " --set"}
+	}
+	return layer, nil
+}
@@ -0,0 +1,457 @@
+package config
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/diagram"
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/document"
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/repository"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+	"github.com/xeipuuv/gojsonschema"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// Schemas holds the published JSON Schemas of the benchmark, test suite and test case configs.
+//
+//go:embed schemas/*.schema.json
+var Schemas embed.FS
+
+// ValidationProblem is a problem found in a file of a benchmark.
+type ValidationProblem struct {
+	Path    string
+	Message string
+}
+
+func (p ValidationProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+type benchmarkValidator struct {
+	pricing  *domain.PricingCatalog
+	schemas  map[string]*gojsonschema.Schema
+	problems []ValidationProblem
+}
+
+// Validate checks the configs of the benchmark against the schemas, the referenced input, expected,
+// image and document files, the providers, models and metrics and returns all problems that were found.
+// An error is returned only if the validation itself could not be run.
+func (l *BenchmarkConfigLoader) Validate() ([]ValidationProblem, error) {
+	schemas, err := loadSchemas()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config schemas: %w", err)
+	}
+	v := &benchmarkValidator{schemas: schemas}
+
+	v.pricing, err = LoadPricingCatalog(l.BasePath)
+	if err != nil {
+		v.addProblem(l.BasePath, "invalid pricing catalog: %v", err)
+	}
+
+	var benchmarkConfig domain.BenchmarkConfig
+	if configPath, ok := v.validateConfig(l.BasePath, "benchmark", &benchmarkConfig); ok {
+		for providerName := range benchmarkConfig.Resilience {
+			if !slices.Contains(domain.KnownProviders, providerName) {
+				v.addProblem(configPath, "resilience configured for unknown provider %q", providerName)
+			}
+		}
+	}
+
+	suiteDirs, err := os.ReadDir(l.BasePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read benchmark directory: %w", err)
+	}
+	suites := 0
+	for _, suiteDir := range suiteDirs {
+		if suiteDir.IsDir() && suiteDir.Name() != FixturesDir {
+			suites++
+			v.validateTestSuite(filepath.Join(l.BasePath, suiteDir.Name()))
+		}
+	}
+	if suites == 0 {
+		v.addProblem(l.BasePath, "benchmark has no test suites")
+	}
+
+	return v.problems, nil
+}
+
+func (v *benchmarkValidator) validateTestSuite(suitePath string) {
+	var suite domain.TestSuiteConfig
+	if configPath, ok := v.validateConfig(suitePath, "test_suite", &suite); ok {
+		v.validateModel(configPath, suite.Provider, suite.Model)
+		v.validateMetrics(configPath, suite.MetricConfigs)
+	}
+
+	suite.Path = suitePath
+
+	caseDirs, err := os.ReadDir(suitePath)
+	if err != nil {
+		v.addProblem(suitePath, "failed to read test suite directory: %v", err)
+		return
+	}
+	cases := map[string]bool{}
+	for _, caseDir := range caseDirs {
+		if caseDir.IsDir() {
+			cases[caseDir.Name()] = true
+			v.validateTestCase(filepath.Join(suitePath, caseDir.Name()), &suite)
+		}
+	}
+	if suite.Cases != "" {
+		v.validateDataset(filepath.Join(suitePath, suite.Cases), cases, &suite)
+	}
+	if len(cases) == 0 {
+		v.addProblem(suitePath, "test suite has no test cases")
+	}
+}
+
+func (v *benchmarkValidator) validateTestCase(casePath string, suite *domain.TestSuiteConfig) {
+	var testCase testCaseFile
+	configPath, ok := v.validateConfig(casePath, "test_case", &testCase)
+	if !ok {
+		return
+	}
+	if len(testCase.Conversation) > 0 {
+		if testCase.Input != "" || testCase.InputText != "" {
+			v.addProblem(configPath, "conversation and input are both set, use only one of them")
+		}
+		v.validateConversation(configPath, testCase.Conversation)
+	} else {
+		v.validateTextOrFile(configPath, "input", testCase.Input, testCase.InputText)
+	}
+	if testCase.Expected != "" || testCase.ExpectedText != "" || !testCase.conversationExpected() {
+		v.validateTextOrFile(configPath, "expected", testCase.Expected, testCase.ExpectedText)
+	}
+	v.validateMetrics(configPath, testCase.MetricConfigs)
+	if testCase.Tools != nil {
+		v.validateTools(configPath, casePath, &testCase.TestCaseConfig, suite)
+	}
+	if testCase.Repository != nil {
+		v.validateRepository(configPath, casePath, testCase.Repository)
+	}
+
+	for _, fileName := range []string{testCase.Input, testCase.Expected} {
+		if fileName == "" {
+			// Given inline
+			continue
+		}
+		path := filepath.Join(casePath, fileName)
+		if _, err := os.ReadFile(path); err != nil {
+			v.addProblem(path, "file is not readable: %v", err)
+		}
+	}
+	for _, imageName := range testCase.Images {
+		v.validateImage(filepath.Join(casePath, imageName))
+	}
+	for _, documentName := range testCase.Documents {
+		v.validateDocument(filepath.Join(casePath, documentName))
+	}
+	for _, diagramName := range testCase.Diagrams {
+		v.validateDiagram(filepath.Join(casePath, diagramName), suite)
+	}
+}
+
+// validateDataset checks every test case of a dataset file against the schema, its images,
+// documents, diagrams, tools, repository and metrics, and that its name is unique in the test suite.
+func (v *benchmarkValidator) validateDataset(path string, cases map[string]bool, suite *domain.TestSuiteConfig) {
+	records, err := readDataset(path)
+	if err != nil {
+		v.addProblem(path, "%v", err)
+		return
+	}
+
+	for _, record := range records {
+		location := fmt.Sprintf("%s:%d", path, record.Line)
+		result, err := v.schemas["dataset_case"].Validate(gojsonschema.NewBytesLoader(record.Data))
+		if err != nil {
+			v.addProblem(location, "invalid JSON: %v", err)
+			continue
+		}
+		for _, resultError := range result.Errors() {
+			v.addProblem(location, "%s: %s", resultError.Field(), resultError.Description())
+		}
+
+		var testCase datasetCase
+		if err := json.Unmarshal(record.Data, &testCase); err != nil {
+			v.addProblem(location, "failed to decode test case: %v", err)
+			continue
+		}
+		if cases[testCase.Name] {
+			v.addProblem(location, "duplicate test case %q", testCase.Name)
+		}
+		cases[testCase.Name] = true
+		if len(testCase.Conversation) > 0 {
+			v.validateConversation(location, testCase.Conversation)
+		}
+		v.validateMetrics(location, testCase.Metrics)
+		if testCase.Tools != nil {
+			testCaseConfig := testCase.testCaseConfig(filepath.Dir(path))
+			v.validateTools(location, filepath.Dir(path), &testCaseConfig, suite)
+		}
+		if testCase.Repository != nil {
+			v.validateRepository(location, filepath.Dir(path), testCase.Repository)
+		}
+		for _, imageName := range testCase.Images {
+			v.validateImage(filepath.Join(filepath.Dir(path), imageName))
+		}
+		for _, documentName := range testCase.Documents {
+			v.validateDocument(filepath.Join(filepath.Dir(path), documentName))
+		}
+		for _, diagramName := range testCase.Diagrams {
+			v.validateDiagram(filepath.Join(filepath.Dir(path), diagramName), suite)
+		}
+	}
+}
+
+// validateConfig checks the config file in dir against its schema and decodes it into config. It
+// returns the path of the config file and false if the file could not be read or decoded.
+func (v *benchmarkValidator) validateConfig(dir, schemaName string, config any) (string, bool) {
+	data, path, err := readConfigFile(dir)
+	if err != nil {
+		v.addProblem(path, "failed to read config: %v", err)
+		return path, false
+	}
+
+	result, err := v.schemas[schemaName].Validate(gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		v.addProblem(path, "invalid JSON: %v", err)
+		return path, false
+	}
+	for _, resultError := range result.Errors() {
+		v.addProblem(path, "%s: %s", resultError.Field(), resultError.Description())
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		v.addProblem(path, "failed to decode config: %v", err)
+		return path, false
+	}
+	return path, true
+}
+
+func (v *benchmarkValidator) validateModel(path, providerName, modelName string) {
+	if providerName == "" || modelName == "" {
+		// Reported by the schema
+		return
+	}
+	if !slices.Contains(domain.KnownProviders, providerName) {
+		v.addProblem(path, "unknown provider %q, known providers are %s", providerName, strings.Join(domain.KnownProviders, ", "))
+		return
+	}
+	if providerName == "mock" || v.pricing == nil {
+		// The mock provider accepts any model
+		return
+	}
+	if models := v.pricing.Models(providerName); !slices.Contains(models, modelName) {
+		v.addProblem(path, "unknown model %q of provider %s, known models are %s", modelName, providerName, strings.Join(models, ", "))
+	}
+}
+
+// validateMetrics checks that the metrics are known and configured once and, if any weight is set,
+// that the weights add up to 1. An unset weight counts as 1, as in the rating.
+func (v *benchmarkValidator) validateMetrics(path string, metricConfigs []domain.MetricConfig) {
+	weights := 0.0
+	weighted := false
+	seen := map[string]bool{}
+	for _, metricConfig := range metricConfigs {
+		if !slices.Contains(domain.KnownMetrics, metricConfig.Name) {
+			v.addProblem(path, "unknown metric %q, known metrics are %s", metricConfig.Name, strings.Join(domain.KnownMetrics, ", "))
+		}
+		if seen[metricConfig.Name] {
+			v.addProblem(path, "metric %q is configured more than once", metricConfig.Name)
+		}
+		seen[metricConfig.Name] = true
+		if metricConfig.Weight == 0 {
+			weights++
+		} else {
+			weights += metricConfig.Weight
+			weighted = true
+		}
+	}
+	if weighted && math.Abs(weights-1) > 1e-6 {
+		v.addProblem(path, "metric weights add up to %g instead of 1", weights)
+	}
+}
+
+// validateTextOrFile checks that a test case gives either a file or the inline text of a field.
+func (v *benchmarkValidator) validateTextOrFile(path, field, fileName, text string) {
+	switch {
+	case fileName == "" && text == "":
+		v.addProblem(path, "%s or %s_text is required", field, field)
+	case fileName != "" && text != "":
+		v.addProblem(path, "%s and %s_text are both set, use only one of them", field, field)
+	}
+}
+
+// validateConversation checks that a conversation ends with a user turn and that only the user
+// turns the model answers have an expected answer.
+func (v *benchmarkValidator) validateConversation(path string, conversation []domain.Turn) {
+	if conversation[len(conversation)-1].Role != domain.RoleUser {
+		v.addProblem(path, "conversation must end with a user turn")
+	}
+	for i, turn := range conversation {
+		switch {
+		case turn.Expected == "":
+		case turn.Role == domain.RoleAssistant:
+			v.addProblem(path, "conversation turn %d: only user turns can have an expected answer", i+1)
+		case i+1 < len(conversation) && conversation[i+1].Role == domain.RoleAssistant:
+			v.addProblem(path, "conversation turn %d: the turn is answered by a scripted assistant turn, its expected answer is never scored", i+1)
+		}
+	}
+}
+
+// validateTools checks the fixture and the tools of a test case and that its expected tool calls
+// are scored.
+func (v *benchmarkValidator) validateTools(path, dir string, testCase *domain.TestCaseConfig, suite *domain.TestSuiteConfig) {
+	tools := testCase.Tools
+	if len(testCase.Conversation) > 0 {
+		v.addProblem(path, "%v", errToolsInConversation)
+	}
+	if fixture, err := repository.Open(filepath.Join(dir, tools.Fixture)); err != nil {
+		v.addProblem(path, "invalid fixture: %v", err)
+	} else if _, err := repository.NewToolbox(fixture, tools.Allow); err != nil {
+		v.addProblem(path, "%v", err)
+	}
+
+	allowed := tools.Allow
+	if len(allowed) == 0 {
+		allowed = repository.ToolNames()
+	}
+	for i, expected := range tools.ExpectedCalls {
+		if !slices.Contains(allowed, expected.Name) {
+			v.addProblem(path, "expected tool call %d: tool %q is not allowed", i+1, expected.Name)
+		}
+	}
+	scored := slices.ContainsFunc(suite.CaseMetrics(testCase), func(metricConfig domain.MetricConfig) bool {
+		return metricConfig.Name == "tool_trajectory"
+	})
+	if len(tools.ExpectedCalls) > 0 && !scored {
+		v.addProblem(path, "expected tool calls are only scored with the tool_trajectory metric")
+	}
+}
+
+// validateRepository checks the source tree of a repository test case and that every include glob
+// matches a file.
+func (v *benchmarkValidator) validateRepository(path, dir string, config *domain.RepositoryConfig) {
+	source, err := repository.Open(filepath.Join(dir, config.Path))
+	if err != nil {
+		v.addProblem(path, "invalid repository: %v", err)
+		return
+	}
+	if _, err := repository.CompileGlobs(config.Exclude); err != nil {
+		v.addProblem(path, "invalid exclude: %v", err)
+	}
+	include, err := repository.CompileGlobs(config.Include)
+	if err != nil {
+		v.addProblem(path, "invalid include: %v", err)
+		return
+	}
+	files, err := source.Files(".")
+	if err != nil {
+		v.addProblem(path, "invalid repository: %v", err)
+		return
+	}
+	for i, pattern := range config.Include {
+		if !slices.ContainsFunc(files, func(file string) bool { return include[i:i+1].Match(file) >= 0 }) {
+			v.addProblem(path, "include %q matches no file of the repository", pattern)
+		}
+	}
+}
+
+func (v *benchmarkValidator) validateImage(path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		v.addProblem(path, "image is not readable: %v", err)
+		return
+	}
+	defer file.Close()
+
+	if _, _, err := image.DecodeConfig(file); err != nil {
+		v.addProblem(path, "not a readable image: %v", err)
+	}
+}
+
+// validateDocument checks that a document is a text file or a PDF with extractable text. A PDF
+// without text, e.g. a scan, can only be read by models with native PDF support.
+func (v *benchmarkValidator) validateDocument(path string) {
+	if _, err := document.Load(path, false); err != nil {
+		if _, nativeErr := document.Load(path, true); nativeErr == nil {
+			v.addProblem(path, "%v, only models with native PDF support can read it", errors.Unwrap(err))
+			return
+		}
+		v.addProblem(path, "invalid document: %v", err)
+	}
+}
+
+// validateDiagram checks that a diagram file is readable and of a known type and, in image mode,
+// that its renderer is installed.
+func (v *benchmarkValidator) validateDiagram(path string, suite *domain.TestSuiteConfig) {
+	diagrams := suite.Diagrams
+	if _, err := os.ReadFile(path); err != nil {
+		v.addProblem(path, "diagram is not readable: %v", err)
+		return
+	}
+	kind, err := domain.DiagramKindOf(path)
+	if err != nil {
+		v.addProblem(path, "%v", err)
+		return
+	}
+	if diagrams.Mode != domain.DiagramModeImage {
+		return
+	}
+	command := diagrams.Renderer(kind)
+	if command == "" {
+		v.addProblem(path, "no renderer configured for %s diagrams, set diagrams.renderers.%s in the test suite", kind, kind)
+		return
+	}
+	if err := diagram.LookupRenderer(command, suite.Path); err != nil {
+		v.addProblem(path, "%v", err)
+	}
+}
+
+func (v *benchmarkValidator) addProblem(path, format string, args ...any) {
+	v.problems = append(v.problems, ValidationProblem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// loadSchemas compiles the embedded schemas, keyed by their file name without the extension.
+func loadSchemas() (map[string]*gojsonschema.Schema, error) {
+	// Referenced by the other schemas
+	var shared []gojsonschema.JSONLoader
+	for _, name := range []string{"generation", "metrics", "mock", "repository", "tools"} {
+		data, err := Schemas.ReadFile("schemas/" + name + ".schema.json")
+		if err != nil {
+			return nil, err
+		}
+		shared = append(shared, gojsonschema.NewBytesLoader(data))
+	}
+
+	schemas := map[string]*gojsonschema.Schema{}
+	for _, name := range []string{"benchmark", "test_suite", "test_case", "dataset_case"} {
+		data, err := Schemas.ReadFile("schemas/" + name + ".schema.json")
+		if err != nil {
+			return nil, err
+		}
+
+		loader := gojsonschema.NewSchemaLoader()
+		if err := loader.AddSchemas(shared...); err != nil {
+			return nil, fmt.Errorf("shared schemas: %w", err)
+		}
+		schema, err := loader.Compile(gojsonschema.NewBytesLoader(data))
+		if err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+		schemas[name] = schema
+	}
+	return schemas, nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+func TestValidateMetricWeights(t *testing.T) {
+	tests := []struct {
+		name         string
+		metrics      []domain.MetricConfig
+		wantProblems int
+	}{
+		{name: "unset", metrics: []domain.MetricConfig{{Name: "geval"}, {Name: "relevance"}}},
+		{name: "add up to 1", metrics: []domain.MetricConfig{{Name: "geval", Weight: 0.7}, {Name: "relevance", Weight: 0.3}}},
+		{name: "single", metrics: []domain.MetricConfig{{Name: "geval", Weight: 1}}},
+		{name: "add up to 2", metrics: []domain.MetricConfig{{Name: "geval", Weight: 1}, {Name: "relevance", Weight: 1}}, wantProblems: 1},
+		// An unset weight counts as 1
+		{name: "partly set", metrics: []domain.MetricConfig{{Name: "geval", Weight: 0.5}, {Name: "relevance"}}, wantProblems: 1},
+		{name: "unknown metric", metrics: []domain.MetricConfig{{Name: "bleu", Weight: 1}}, wantProblems: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &benchmarkValidator{}
+			v.validateMetrics("config.json", tt.metrics)
+			if len(v.problems) != tt.wantProblems {
+				t.Errorf("problems = %v, want %d", v.problems, tt.wantProblems)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package diagram
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Placeholders of a render command for the diagram file and the PNG file.
+const (
+	InputPlaceholder  = "{input}"
+	OutputPlaceholder = "{output}"
+)
+
+// Render renders a diagram file to a PNG with a local command and returns the path of the PNG.
+// Without {input} the diagram source is piped to the command, without {output} the PNG is read
+// from its stdout. The command runs in suiteDir, the test suite directory, see ParseCommand. The
+// PNGs are cached in dir by the command and the source, so repetitions and runs with the response
+// cache send the same image.
+func Render(ctx context.Context, path, command, suiteDir, dir string) (string, error) {
+	args, source, pngPath, err := renderTarget(path, command, suiteDir, dir)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(pngPath); err == nil {
+		return pngPath, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(pngPath), 0755); err != nil {
+		return "", fmt.Errorf("error creating diagram directory: %w", err)
+	}
+
+	// Some renderers pick the format by the extension of the output file
+	output, err := os.CreateTemp(filepath.Dir(pngPath), "render-*.png")
+	if err != nil {
+		return "", fmt.Errorf("error creating output file: %w", err)
+	}
+	output.Close()
+	defer os.Remove(output.Name())
+
+	// The command runs in the test suite directory, the files are passed with absolute paths
+	input, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("error resolving diagram path: %w", err)
+	}
+	for i := range args {
+		args[i] = strings.ReplaceAll(args[i], InputPlaceholder, input)
+		args[i] = strings.ReplaceAll(args[i], OutputPlaceholder, output.Name())
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = suiteDir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if !strings.Contains(command, InputPlaceholder) {
+		cmd.Stdin = bytes.NewReader(source)
+	}
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			err = fmt.Errorf("%v: %s", err, message)
+		}
+		return "", fmt.Errorf("error rendering %s with %q: %w", filepath.Base(path), command, err)
+	}
+
+	png := stdout.Bytes()
+	if strings.Contains(command, OutputPlaceholder) {
+		if png, err = os.ReadFile(output.Name()); err != nil {
+			return "", fmt.Errorf("error reading the rendered diagram: %w", err)
+		}
+	}
+	if http.DetectContentType(png) != "image/png" {
+		return "", fmt.Errorf("rendering %s with %q did not produce a PNG", filepath.Base(path), command)
+	}
+	// Renamed into place, so an interrupted render never leaves a broken PNG in the cache
+	if err := os.WriteFile(output.Name(), png, 0644); err != nil {
+		return "", fmt.Errorf("error writing the rendered diagram: %w", err)
+	}
+	if err := os.Rename(output.Name(), pngPath); err != nil {
+		return "", fmt.Errorf("error writing the rendered diagram: %w", err)
+	}
+	return pngPath, nil
+}
+
+// Rendered returns the cached PNG of a diagram without running the render command, false if the
+// diagram was not rendered yet, e.g. for the cost estimate.
+func Rendered(path, command, suiteDir, dir string) (string, bool, error) {
+	_, _, pngPath, err := renderTarget(path, command, suiteDir, dir)
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := os.Stat(pngPath); err != nil {
+		return "", false, nil
+	}
+	return pngPath, true, nil
+}
+
+// renderTarget parses the command and returns its arguments, the diagram source and the path of the
+// cached PNG. The cache key holds the resolved program, so two test suites with a ./render.sh of
+// their own do not share renders.
+func renderTarget(path, command, suiteDir, dir string) ([]string, []byte, string, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("error reading diagram: %w", err)
+	}
+	args, err := ParseCommand(command, suiteDir)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, nil, "", fmt.Errorf("error resolving diagram directory: %w", err)
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join(args, "\x00") + "\n" + string(source)))
+	return args, source, filepath.Join(dir, hex.EncodeToString(hash[:])+".png"), nil
+}
+
+// ParseCommand splits a render command into its arguments by shell word rules: single and double
+// quotes group words, e.g. paths with spaces, and a backslash escapes the next character. There is
+// no expansion of variables or globs. A relative program path such as ./render.sh is resolved
+// against suiteDir, a program name without a path is looked up in PATH.
+func ParseCommand(command, suiteDir string) ([]string, error) {
+	args, err := splitWords(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty render command")
+	}
+	if strings.ContainsRune(args[0], '/') && !filepath.IsAbs(args[0]) {
+		args[0] = filepath.Join(suiteDir, args[0])
+	}
+	return args, nil
+}
+
+func splitWords(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord, escaped := false, false
+	var quote rune
+	for _, r := range command {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in render command %q", command)
+	}
+	if escaped {
+		return nil, fmt.Errorf("render command %q ends with a backslash", command)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// LookupRenderer checks that the program of a render command is installed, or for a program path
+// that it exists relative to the test suite directory.
+func LookupRenderer(command, suiteDir string) error {
+	args, err := ParseCommand(command, suiteDir)
+	if err != nil {
+		return err
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return fmt.Errorf("renderer %s is not installed: %w", args[0], err)
+	}
+	return nil
+}
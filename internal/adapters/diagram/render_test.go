@@ -0,0 +1,77 @@
+package diagram
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+		wantErr string
+	}{
+		{command: "plantuml -tpng -pipe", want: []string{"plantuml", "-tpng", "-pipe"}},
+		{command: `mmdc -i {input} -c "my config.json"`, want: []string{"mmdc", "-i", "{input}", "-c", "my config.json"}},
+		{command: `render '{input}' a\ b "say \"hi\""`, want: []string{"render", "{input}", "a b", `say "hi"`}},
+		{command: `render ""`, want: []string{"render", ""}},
+		{command: "./render-structurizr.sh {input} {output}", want: []string{"/suite/render-structurizr.sh", "{input}", "{output}"}},
+		{command: "scripts/render.sh", want: []string{"/suite/scripts/render.sh"}},
+		{command: "/usr/bin/render", want: []string{"/usr/bin/render"}},
+		{command: "  ", wantErr: "empty render command"},
+		{command: `render "unterminated`, wantErr: "unterminated quote"},
+		{command: `render \`, wantErr: "backslash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			args, err := ParseCommand(tt.command, "/suite")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(args, tt.want) {
+				t.Errorf("args = %q, %v, want %q", args, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestRenderRelativeCommand renders with a script in the test suite directory, with spaces in its
+// path, while the working directory is elsewhere.
+func TestRenderRelativeCommand(t *testing.T) {
+	suiteDir := filepath.Join(t.TempDir(), "my suite")
+	if err := os.MkdirAll(suiteDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\nprintf '\\211PNG\\r\\n\\032\\n' > \"$2\"\necho rendered >> calls.txt\n"
+	if err := os.WriteFile(filepath.Join(suiteDir, "render tool.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	diagramPath := filepath.Join(suiteDir, "context.dsl")
+	if err := os.WriteFile(diagramPath, []byte("workspace {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	command := `"./render tool.sh" {input} {output}`
+	dir := t.TempDir()
+
+	if _, rendered, err := Rendered(diagramPath, command, suiteDir, dir); err != nil || rendered {
+		t.Fatalf("rendered before rendering = %v, %v", rendered, err)
+	}
+	pngPath, err := Render(context.Background(), diagramPath, command, suiteDir, dir)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if cached, rendered, err := Rendered(diagramPath, command, suiteDir, dir); err != nil || !rendered || cached != pngPath {
+		t.Errorf("rendered = %q, %v, %v, want %q", cached, rendered, err, pngPath)
+	}
+	// The script ran in the test suite directory
+	if calls, err := os.ReadFile(filepath.Join(suiteDir, "calls.txt")); err != nil || string(calls) != "rendered\n" {
+		t.Errorf("calls = %q, %v", calls, err)
+	}
+}
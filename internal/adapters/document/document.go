@@ -0,0 +1,87 @@
+package document
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+	"github.com/ledongthuc/pdf"
+)
+
+const PDFMimeType = "application/pdf"
+
+// Load reads a document that is attached to a test case: a PDF or a text file like Markdown. The
+// text of a PDF is extracted in pure Go. With nativePDF the PDF itself is attached as well, then a
+// PDF without extractable text, e.g. a scan, is no error.
+func Load(path string, nativePDF bool) (domain.Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return domain.Document{}, fmt.Errorf("error reading document: %w", err)
+	}
+	document := domain.Document{Name: filepath.Base(path)}
+
+	if http.DetectContentType(data) == PDFMimeType {
+		document.MimeType = PDFMimeType
+		text, err := ExtractPDFText(data)
+		if err != nil && !nativePDF {
+			return domain.Document{}, fmt.Errorf("error extracting the PDF text: %w", err)
+		}
+		document.Text = text
+		if nativePDF {
+			document.Data = base64.StdEncoding.EncodeToString(data)
+		}
+		return document, nil
+	}
+
+	if !utf8.Valid(data) {
+		return domain.Document{}, fmt.Errorf("neither a PDF nor a UTF-8 text file")
+	}
+	document.MimeType = "text/plain"
+	if mimeType := mime.TypeByExtension(filepath.Ext(path)); strings.HasPrefix(mimeType, "text/") {
+		document.MimeType = mimeType
+	}
+	document.Text = string(data)
+	return document, nil
+}
+
+// ExtractPDFText returns the plain text of a PDF, page by page. Pages are not rendered, so the
+// content of figures and scanned pages is lost.
+func ExtractPDFText(data []byte) (text string, err error) {
+	// The parser panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("invalid PDF: %w", err)
+	}
+
+	var pages []string
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		pageText, err := page.GetPlainText(nil)
+		if err != nil {
+			return "", fmt.Errorf("page %d: %w", i, err)
+		}
+		if pageText = strings.TrimSpace(pageText); pageText != "" {
+			pages = append(pages, fmt.Sprintf("[Page %d]\n%s", i, pageText))
+		}
+	}
+	if len(pages) == 0 {
+		return "", fmt.Errorf("the PDF has no extractable text")
+	}
+	return strings.Join(pages, "\n\n"), nil
+}
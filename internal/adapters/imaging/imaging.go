@@ -0,0 +1,234 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"slices"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	defaultJPEGQuality = 90
+	defaultMaxTiles    = 4
+	// Downscaling steps for images that exceed the byte limit even as JPEG
+	maxShrinkSteps = 8
+	shrinkFactor   = 0.75
+)
+
+// Options are the effective settings of the pipeline: the image config of a test suite within the
+// limits of the provider.
+type Options struct {
+	MaxSize     int
+	MaxBytes    int
+	Format      string
+	JPEGQuality int
+	Tile        bool
+	MaxTiles    int
+	// MimeTypes are the formats that are sent unconverted, all formats if empty
+	MimeTypes []string
+}
+
+// NewOptions caps the image config of a test suite by the limits of the provider.
+func NewOptions(config domain.ImageConfig, limits domain.ImageLimits) Options {
+	options := Options{
+		MaxSize:     capLimit(config.MaxSize, limits.MaxSize),
+		MaxBytes:    capLimit(config.MaxBytes, limits.MaxBytes),
+		Format:      config.Format,
+		JPEGQuality: config.JPEGQuality,
+		Tile:        config.Tile,
+		MaxTiles:    config.MaxTiles,
+		MimeTypes:   limits.MimeTypes,
+	}
+	if options.JPEGQuality == 0 {
+		options.JPEGQuality = defaultJPEGQuality
+	}
+	if options.MaxTiles == 0 {
+		options.MaxTiles = defaultMaxTiles
+	}
+	return options
+}
+
+// capLimit returns the configured value if it is set and within the limit, the limit otherwise.
+func capLimit(value, limit int) int {
+	if value > 0 && (limit == 0 || value < limit) {
+		return value
+	}
+	return limit
+}
+
+// Process prepares an image file for a provider: it is downscaled or tiled to the maximum size,
+// converted if its format is not accepted and compressed to the byte limit. An image that needs
+// none of this is sent as it is. Tiles are returned row by row.
+func Process(data []byte, options Options) ([]domain.Image, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image: %w", err)
+	}
+	mimeType := "image/" + format
+
+	width, height, tileSize := plan(config.Width, config.Height, options)
+	accepted := len(options.MimeTypes) == 0 || slices.Contains(options.MimeTypes, mimeType)
+	if width == config.Width && tileSize == 0 && accepted && (options.Format == "" || options.Format == format) && fits(data, options) {
+		return []domain.Image{{
+			MimeType: mimeType,
+			Data:     base64.StdEncoding.EncodeToString(data),
+			Width:    config.Width,
+			Height:   config.Height,
+		}}, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+	img = resize(img, width, height)
+
+	outputFormat := options.Format
+	if outputFormat == "" {
+		outputFormat = domain.ImageFormatPNG
+		if format == domain.ImageFormatJPEG {
+			outputFormat = domain.ImageFormatJPEG
+		}
+	}
+
+	var images []domain.Image
+	for _, tile := range tiles(img.Bounds(), tileSize) {
+		encoded, err := encodeWithin(subImage(img, tile), outputFormat, options)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, encoded)
+	}
+	return images, nil
+}
+
+// Sizes returns the sizes of the images that Process sends for an image of the given size, e.g.
+// to estimate their tokens. Images that have to be compressed further are not accounted for.
+func Sizes(width, height int, options Options) []image.Point {
+	width, height, tileSize := plan(width, height, options)
+	var sizes []image.Point
+	for _, tile := range tiles(image.Rect(0, 0, width, height), tileSize) {
+		sizes = append(sizes, tile.Size())
+	}
+	return sizes
+}
+
+// plan returns the size an image is scaled to and the size of its tiles, 0 if it is not tiled.
+func plan(width, height int, options Options) (int, int, int) {
+	maxSize := options.MaxSize
+	if maxSize == 0 || (width <= maxSize && height <= maxSize) {
+		return width, height, 0
+	}
+	if !options.Tile {
+		scale := float64(maxSize) / float64(max(width, height))
+		return scaled(width, scale), scaled(height, scale), 0
+	}
+
+	// The largest scale at which the image splits into at most MaxTiles tiles
+	scale := 0.0
+	for columns := 1; columns <= options.MaxTiles; columns++ {
+		rows := options.MaxTiles / columns
+		gridScale := math.Min(1, math.Min(float64(columns*maxSize)/float64(width), float64(rows*maxSize)/float64(height)))
+		scale = math.Max(scale, gridScale)
+	}
+	return scaled(width, scale), scaled(height, scale), maxSize
+}
+
+func scaled(size int, scale float64) int {
+	return max(1, int(math.Floor(float64(size)*scale)))
+}
+
+// tiles splits the bounds into tiles of at most tileSize, row by row.
+func tiles(bounds image.Rectangle, tileSize int) []image.Rectangle {
+	if tileSize == 0 {
+		return []image.Rectangle{bounds}
+	}
+	var rects []image.Rectangle
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += tileSize {
+		for x := bounds.Min.X; x < bounds.Max.X; x += tileSize {
+			rects = append(rects, image.Rect(x, y, x+tileSize, y+tileSize).Intersect(bounds))
+		}
+	}
+	return rects
+}
+
+func resize(img image.Image, width, height int) image.Image {
+	if img.Bounds().Dx() == width && img.Bounds().Dy() == height {
+		return img
+	}
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return resized
+}
+
+func subImage(img image.Image, rect image.Rectangle) image.Image {
+	if rect == img.Bounds() {
+		return img
+	}
+	cropped := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(cropped, cropped.Bounds(), img, rect.Min, draw.Src)
+	return cropped
+}
+
+// encodeWithin encodes the image in the format. An image over the byte limit is encoded as JPEG
+// and, if it is still too large, downscaled step by step.
+func encodeWithin(img image.Image, format string, options Options) (domain.Image, error) {
+	for step := 0; ; step++ {
+		data, err := encode(img, format, options.JPEGQuality)
+		if err != nil {
+			return domain.Image{}, err
+		}
+		if fits(data, options) {
+			return domain.Image{
+				MimeType: "image/" + format,
+				Data:     base64.StdEncoding.EncodeToString(data),
+				Width:    img.Bounds().Dx(),
+				Height:   img.Bounds().Dy(),
+			}, nil
+		}
+		if step == maxShrinkSteps {
+			return domain.Image{}, fmt.Errorf("image exceeds the limit of %d bytes even after downscaling", options.MaxBytes)
+		}
+		if format != domain.ImageFormatJPEG {
+			format = domain.ImageFormatJPEG
+			continue
+		}
+		img = resize(img, scaled(img.Bounds().Dx(), shrinkFactor), scaled(img.Bounds().Dy(), shrinkFactor))
+	}
+}
+
+func encode(img image.Image, format string, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case domain.ImageFormatPNG:
+		err = png.Encode(&buf, img)
+	case domain.ImageFormatJPEG:
+		// JPEG has no transparency, transparent areas would turn black
+		opaque := image.NewRGBA(img.Bounds())
+		draw.Draw(opaque, opaque.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(opaque, opaque.Bounds(), img, img.Bounds().Min, draw.Over)
+		err = jpeg.Encode(&buf, opaque, &jpeg.Options{Quality: quality})
+	default:
+		return nil, fmt.Errorf("unsupported image format %q, expected png or jpeg", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error encoding image as %s: %w", format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func fits(data []byte, options Options) bool {
+	return options.MaxBytes == 0 || len(data) <= options.MaxBytes
+}
@@ -0,0 +1,173 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/ports"
+)
+
+type cacheSampleKey struct{}
+
+// WithCacheSample sets the sample number, e.g. the repetition of a test case, that is part of the
+// cache key. Without it every repetition of a test case would get the same cached response.
+func WithCacheSample(ctx context.Context, sample int) context.Context {
+	return context.WithValue(ctx, cacheSampleKey{}, sample)
+}
+
+func cacheSample(ctx context.Context) int {
+	sample, _ := ctx.Value(cacheSampleKey{}).(int)
+	return sample
+}
+
+// CachingProvider decorates a provider with an on-disk response cache, keyed by a hash of the
+// provider, model, prompts, images, generation parameters and response schema.
+type CachingProvider struct {
+	provider     ports.LLMProvider
+	dir          string
+	providerName string
+	model        string
+	mode         domain.CacheMode
+}
+
+func NewCachingProvider(provider ports.LLMProvider, dir, providerName, model string, mode domain.CacheMode) ports.LLMProvider {
+	return &CachingProvider{
+		provider:     provider,
+		dir:          dir,
+		providerName: providerName,
+		model:        model,
+		mode:         mode,
+	}
+}
+
+// cacheEntry is a cached response as it is stored on disk.
+type cacheEntry struct {
+	Key                string                     `json:"key"`
+	Provider           string                     `json:"provider"`
+	Model              string                     `json:"model"`
+	CreatedAt          time.Time                  `json:"created_at"`
+	Response           *domain.LLMResponse        `json:"response,omitempty"`
+	StructuredResponse *domain.StructuredResponse `json:"structured_response,omitempty"`
+}
+
+func (p *CachingProvider) GenerateResponse(ctx context.Context, request domain.LLMRequest) (domain.LLMResponse, error) {
+	key, err := requestKey(p.providerName, p.model, cacheSample(ctx), request, nil)
+	if err != nil {
+		return domain.LLMResponse{}, err
+	}
+	if entry, ok := p.read(key); ok && entry.Response != nil {
+		return cachedResponse(*entry.Response), nil
+	}
+
+	response, err := p.provider.GenerateResponse(ctx, request)
+	if err != nil {
+		return domain.LLMResponse{}, err
+	}
+	p.write(cacheEntry{Key: key, Response: &response})
+	return response, nil
+}
+
+func (p *CachingProvider) GenerateStructuredResponse(ctx context.Context, request domain.LLMRequest, schema domain.StructuredOutput) (domain.StructuredResponse, error) {
+	key, err := requestKey(p.providerName, p.model, cacheSample(ctx), request, &schema)
+	if err != nil {
+		return domain.StructuredResponse{}, err
+	}
+	if entry, ok := p.read(key); ok && entry.StructuredResponse != nil {
+		response := *entry.StructuredResponse
+		response.Cost = 0
+		response.Cached = true
+		return response, nil
+	}
+
+	response, err := p.provider.GenerateStructuredResponse(ctx, request, schema)
+	if err != nil {
+		return domain.StructuredResponse{}, err
+	}
+	p.write(cacheEntry{Key: key, StructuredResponse: &response})
+	return response, nil
+}
+
+func (p *CachingProvider) GetModels() []string {
+	return p.provider.GetModels()
+}
+
+// cachedResponse marks a response as served from the cache, it did not cost anything.
+func cachedResponse(response domain.LLMResponse) domain.LLMResponse {
+	response.Cost = 0
+	response.Cached = true
+	return response
+}
+
+// requestKey hashes everything that determines the response of a request. It is the key of the
+// response cache and of the replay fixtures.
+func requestKey(providerName, model string, sample int, request domain.LLMRequest, schema *domain.StructuredOutput) (string, error) {
+	data, err := json.Marshal(struct {
+		Provider string                   `json:"provider"`
+		Model    string                   `json:"model"`
+		Sample   int                      `json:"sample"`
+		Request  domain.LLMRequest        `json:"request"`
+		Schema   *domain.StructuredOutput `json:"schema,omitempty"`
+	}{providerName, model, sample, request, schema})
+	if err != nil {
+		return "", fmt.Errorf("error building request key: %w", err)
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func (p *CachingProvider) path(key string) string {
+	return filepath.Join(p.dir, key[:2], key+".json")
+}
+
+func (p *CachingProvider) read(key string) (cacheEntry, bool) {
+	if !p.mode.Reads() {
+		return cacheEntry{}, false
+	}
+	data, err := os.ReadFile(p.path(key))
+	if err != nil {
+		return cacheEntry{}, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		fmt.Printf("Warning: ignoring corrupt cache entry %s: %v\n", p.path(key), err)
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+// write stores the entry. A failed write only costs a cache miss later, so it is reported but not returned.
+func (p *CachingProvider) write(entry cacheEntry) {
+	if !p.mode.Writes() {
+		return
+	}
+	entry.Provider = p.providerName
+	entry.Model = p.model
+	entry.CreatedAt = time.Now()
+
+	if err := writeJSONFile(p.path(entry.Key), entry); err != nil {
+		fmt.Printf("Warning: could not write cache entry: %v\n", err)
+	}
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so an interrupted run never leaves a truncated file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,33 @@
+package llm
+
+import "github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+
+// SupportsNativePDF reports whether the model of the provider reads PDF documents itself. Other
+// models get the text that is extracted from the PDF.
+func SupportsNativePDF(providerName, model string) bool {
+	switch providerName {
+	case "openai":
+		return supportsPDF(model)
+	default:
+		return false
+	}
+}
+
+// openAIImageLimits are the limits of the OpenAI API: larger images are scaled to fit into
+// 2048x2048 by the API anyway and an image may have up to 20 MB.
+var openAIImageLimits = domain.ImageLimits{
+	MaxSize:   2048,
+	MaxBytes:  20 * 1024 * 1024,
+	MimeTypes: []string{"image/png", "image/jpeg", "image/gif", "image/webp"},
+}
+
+// ImageLimits returns the limits of the provider for images. The mock provider has the limits of
+// OpenAI, so the image pipeline can be tested offline.
+func ImageLimits(providerName string) domain.ImageLimits {
+	switch providerName {
+	case "openai", "mock":
+		return openAIImageLimits
+	default:
+		return domain.ImageLimits{}
+	}
+}
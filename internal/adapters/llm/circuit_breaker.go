@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open, the provider failed repeatedly")
+
+// circuitBreaker stops calling a provider after a number of consecutive failures. After the cooldown
+// a single trial call is let through (half open), its result closes or reopens the circuit.
+type circuitBreaker struct {
+	mu          sync.Mutex
+	threshold   int
+	cooldown    time.Duration
+	failures    int
+	openedAt    time.Time
+	trialActive bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow returns ErrCircuitOpen if calls are currently not allowed. trial is set for the single call
+// that is let through after the cooldown, it has to end with RecordSuccess, RecordFailure or Release.
+func (b *circuitBreaker) Allow() (trial bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return false, nil
+	}
+	if time.Since(b.openedAt) < b.cooldown || b.trialActive {
+		return false, ErrCircuitOpen
+	}
+	b.trialActive = true
+	return true, nil
+}
+
+// Release ends a trial call without a result about the health of the provider, e.g. because it was
+// cancelled or the request was invalid. The circuit stays open and lets the next call through as trial.
+func (b *circuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trialActive = false
+}
+
+func (b *circuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trialActive = false
+}
+
+func (b *circuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trialActive = false
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	breaker := newCircuitBreaker(2, time.Hour)
+
+	breaker.RecordFailure()
+	if _, err := breaker.Allow(); err != nil {
+		t.Fatalf("allow after one failure: %v", err)
+	}
+	breaker.RecordFailure()
+	if _, err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow after threshold = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name string
+		end  func(*circuitBreaker)
+		// The result of the next Allow
+		wantTrial bool
+		wantOpen  bool
+	}{
+		{name: "trial succeeds closes", end: (*circuitBreaker).RecordSuccess},
+		{name: "trial fails reopens", end: (*circuitBreaker).RecordFailure, wantOpen: true},
+		{name: "released trial lets the next trial through", end: (*circuitBreaker).Release, wantTrial: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := newCircuitBreaker(1, 10*time.Millisecond)
+			breaker.RecordFailure()
+			time.Sleep(20 * time.Millisecond)
+
+			trial, err := breaker.Allow()
+			if err != nil || !trial {
+				t.Fatalf("allow after cooldown = %v, %v, want a trial", trial, err)
+			}
+			if _, err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("second call during the trial = %v, want ErrCircuitOpen", err)
+			}
+
+			tt.end(breaker)
+			trial, err = breaker.Allow()
+			if errors.Is(err, ErrCircuitOpen) != tt.wantOpen || trial != tt.wantTrial {
+				t.Errorf("allow = %v, %v, want trial %v and open %v", trial, err, tt.wantTrial, tt.wantOpen)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+var ErrUnknownPrice = errors.New("model not found in pricing catalog")
+
+// CostCalculator interface defines the contract for calculating costs.  This allows for easier mocking and swapping of cost calculation logic.
+type CostCalculator interface {
+	CalculateCost(usage domain.TokenUsage, model string) (float64, error)
+}
+
+// CatalogCostCalculator calculates costs with the prices of a provider that are effective today.
+type CatalogCostCalculator struct {
+	catalog  *domain.PricingCatalog
+	provider string
+}
+
+func NewCatalogCostCalculator(catalog *domain.PricingCatalog, provider string) *CatalogCostCalculator {
+	return &CatalogCostCalculator{catalog: catalog, provider: provider}
+}
+
+func (c *CatalogCostCalculator) CalculateCost(usage domain.TokenUsage, model string) (float64, error) {
+	price, ok := c.catalog.Lookup(c.provider, model, time.Now())
+	if !ok {
+		return 0, fmt.Errorf("%w: %s/%s", ErrUnknownPrice, c.provider, model)
+	}
+	return price.Cost(usage), nil
+}
+
+// warnedModels keeps track of the models an unknown price warning was printed for.
+var warnedModels sync.Map
+
+// calculateCost returns the cost of a call. A model without a known price does not fail the call,
+// a warning is printed once and the cost is marked as unknown instead.
+func calculateCost(calculator CostCalculator, provider string, usage domain.TokenUsage, model string) (float64, bool, error) {
+	cost, err := calculator.CalculateCost(usage, model)
+	if errors.Is(err, ErrUnknownPrice) {
+		if _, warned := warnedModels.LoadOrStore(provider+"/"+model, true); !warned {
+			fmt.Printf("Warning: no price known for %s/%s, its cost is reported as unknown\n", provider, model)
+		}
+		return 0, true, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return cost, false, nil
+}
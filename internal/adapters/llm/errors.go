@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// APIError is a failed provider call with the HTTP status and the time the provider asked to wait before retrying.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("%s API error (status %d): %v", e.Provider, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s API error: %v", e.Provider, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether the call can succeed when it is repeated: rate limits, timeouts,
+// server errors and network errors are retryable, invalid requests are not.
+func (e *APIError) Retryable() bool {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests,
+		e.StatusCode == http.StatusRequestTimeout,
+		e.StatusCode >= http.StatusInternalServerError:
+		return true
+	case e.StatusCode > 0:
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(e.Err, &netErr) || errors.Is(e.Err, io.ErrUnexpectedEOF) || errors.Is(e.Err, io.EOF)
+}
+
+// newOpenAIError wraps an error of the OpenAI client with its HTTP status code.
+func newOpenAIError(err error, info *responseInfo) *APIError {
+	apiErr := &APIError{Provider: "openai", Err: err, RetryAfter: info.retryAfter}
+
+	var openaiAPIErr *openai.APIError
+	var openaiRequestErr *openai.RequestError
+	switch {
+	case errors.As(err, &openaiAPIErr):
+		apiErr.StatusCode = openaiAPIErr.HTTPStatusCode
+	case errors.As(err, &openaiRequestErr):
+		apiErr.StatusCode = openaiRequestErr.HTTPStatusCode
+	}
+	return apiErr
+}
@@ -0,0 +1,35 @@
+package llm
+
+import (
+	"math"
+	"unicode/utf8"
+)
+
+// charsPerToken is the rough average of characters per token for English text and code.
+const charsPerToken = 4
+
+// EstimateTextTokens approximates the token count of a text without a model specific tokenizer. It is
+// a heuristic, real counts differ by model and language.
+func EstimateTextTokens(text string) int {
+	return int(math.Ceil(float64(utf8.RuneCountInString(text)) / charsPerToken))
+}
+
+// EstimateImageTokens approximates the input tokens of an image with high detail: the image is
+// scaled to fit into 2048x2048, then its shortest side to 768px, and every 512px tile costs 170 tokens
+// on top of a base of 85 tokens.
+func EstimateImageTokens(width, height int) int {
+	if width <= 0 || height <= 0 {
+		return 0
+	}
+
+	w, h := float64(width), float64(height)
+	if scale := 2048 / math.Max(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+	if scale := 768 / math.Min(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+
+	tiles := math.Ceil(w/512) * math.Ceil(h/512)
+	return 85 + 170*int(tiles)
+}
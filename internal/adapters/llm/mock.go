@@ -0,0 +1,201 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/ports"
+)
+
+const (
+	defaultMockOutput = "mock response"
+	defaultMockScore  = 50.0
+	// mockTimeout is how long a simulated timeout blocks when the call has no deadline
+	mockTimeout = 30 * time.Second
+)
+
+// errMockMalformedJSON makes a structured response answer with malformed content, there is no such
+// error for text responses.
+var errMockMalformedJSON = errors.New("simulated malformed JSON is only injected into structured responses")
+
+type mockConfigKey struct{}
+
+// WithMockConfig attaches the mock script of a test case to the context of its calls.
+func WithMockConfig(ctx context.Context, config *domain.MockConfig) context.Context {
+	if config == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, mockConfigKey{}, config)
+}
+
+// MockProvider answers from the mock script of the test case without any network access. It
+// simulates latency, cost, token usage and errors to test the engine deterministically.
+type MockProvider struct {
+	model string
+	mu    sync.Mutex
+	// attempts counts the calls per request, to fail only the first attempts of a request
+	attempts map[string]int
+}
+
+func NewMockProvider(model string) ports.LLMProvider {
+	return &MockProvider{model: model, attempts: make(map[string]int)}
+}
+
+func (p *MockProvider) GenerateResponse(ctx context.Context, request domain.LLMRequest) (domain.LLMResponse, error) {
+	config := mockConfig(ctx)
+	if err := p.simulate(ctx, config, config.Error, request, nil); err != nil {
+		return domain.LLMResponse{}, err
+	}
+	if toolCall, ok := mockToolCall(config, request); ok {
+		return domain.LLMResponse{
+			Cost:       config.Cost,
+			Usage:      mockUsage(config, request, toolCall.Name+toolCall.Arguments),
+			Generation: request.Generation,
+			ToolCalls:  []domain.ToolCall{toolCall},
+		}, nil
+	}
+
+	output := config.Output
+	if output == "" {
+		output = defaultMockOutput
+	}
+	return domain.LLMResponse{
+		Response:   output,
+		Cost:       config.Cost,
+		Usage:      mockUsage(config, request, output),
+		Generation: request.Generation,
+	}, nil
+}
+
+func (p *MockProvider) GenerateStructuredResponse(ctx context.Context, request domain.LLMRequest, schema domain.StructuredOutput) (domain.StructuredResponse, error) {
+	config := mockConfig(ctx)
+	score := defaultMockScore
+	if config.Score != nil {
+		score = *config.Score
+	}
+	content, _ := json.Marshal(map[string]float64{"score": score})
+
+	err := p.simulate(ctx, config, config.JudgeError, request, &schema)
+	if errors.Is(err, errMockMalformedJSON) {
+		// Cut off like a truncated response, so the content fails to decode as for a real provider
+		content = content[:len(content)-1]
+	} else if err != nil {
+		return domain.StructuredResponse{}, err
+	}
+	data, err := decodeStructuredContent(string(content))
+	if err != nil {
+		return domain.StructuredResponse{}, err
+	}
+	return domain.StructuredResponse{
+		Data:  data,
+		Cost:  config.Cost,
+		Usage: mockUsage(config, request, string(content)),
+	}, nil
+}
+
+func (p *MockProvider) GetModels() []string {
+	return []string{p.model}
+}
+
+// simulate waits for the scripted latency and returns the scripted error, if any.
+func (p *MockProvider) simulate(ctx context.Context, config *domain.MockConfig, injected string, request domain.LLMRequest, schema *domain.StructuredOutput) error {
+	if err := sleep(ctx, time.Duration(config.LatencyMs)*time.Millisecond); err != nil {
+		return err
+	}
+	if injected == "" || !p.failAttempt(ctx, config, request, schema) {
+		return nil
+	}
+
+	switch injected {
+	case domain.MockErrorRateLimit:
+		return &APIError{Provider: "mock", StatusCode: http.StatusTooManyRequests, Err: fmt.Errorf("simulated rate limit")}
+	case domain.MockErrorServerError:
+		return &APIError{Provider: "mock", StatusCode: http.StatusInternalServerError, Err: fmt.Errorf("simulated server error")}
+	case domain.MockErrorBadRequest:
+		return &APIError{Provider: "mock", StatusCode: http.StatusBadRequest, Err: fmt.Errorf("simulated bad request")}
+	case domain.MockErrorTimeout:
+		// Hang like an unresponsive server until the call is cancelled
+		timer := time.NewTimer(mockTimeout)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		return &APIError{Provider: "mock", Err: context.DeadlineExceeded}
+	case domain.MockErrorMalformedJSON:
+		return errMockMalformedJSON
+	default:
+		return fmt.Errorf("unknown mock error: %s", injected)
+	}
+}
+
+// failAttempt reports whether the current attempt of the request gets the injected error.
+func (p *MockProvider) failAttempt(ctx context.Context, config *domain.MockConfig, request domain.LLMRequest, schema *domain.StructuredOutput) bool {
+	if config.ErrorCount <= 0 {
+		return true
+	}
+	key, err := requestKey("mock", p.model, cacheSample(ctx), request, schema)
+	if err != nil {
+		return true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempts[key]++
+	return p.attempts[key] <= config.ErrorCount
+}
+
+// mockToolCall returns the next scripted tool call, the tool calls answered so far are in the tool
+// messages of the request.
+func mockToolCall(config *domain.MockConfig, request domain.LLMRequest) (domain.ToolCall, bool) {
+	if len(request.Tools) == 0 {
+		return domain.ToolCall{}, false
+	}
+	step := 0
+	for _, message := range request.ToolMessages {
+		if message.Role == domain.RoleAssistant {
+			step++
+		}
+	}
+	if step >= len(config.ToolCalls) {
+		return domain.ToolCall{}, false
+	}
+
+	arguments := []byte("{}")
+	if config.ToolCalls[step].Arguments != nil {
+		arguments, _ = json.Marshal(config.ToolCalls[step].Arguments)
+	}
+	return domain.ToolCall{
+		ID:        fmt.Sprintf("call_%d", step+1),
+		Name:      config.ToolCalls[step].Name,
+		Arguments: string(arguments),
+	}, true
+}
+
+func mockConfig(ctx context.Context) *domain.MockConfig {
+	if config, ok := ctx.Value(mockConfigKey{}).(*domain.MockConfig); ok {
+		return config
+	}
+	return &domain.MockConfig{}
+}
+
+// mockUsage returns the scripted token counts or estimates them from the request and the output.
+func mockUsage(config *domain.MockConfig, request domain.LLMRequest, output string) domain.TokenUsage {
+	usage := domain.TokenUsage{
+		PromptTokens:     config.PromptTokens,
+		CompletionTokens: config.CompletionTokens,
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = estimateRequestTokens(domain.LLMRequest{SystemPrompt: request.SystemPrompt, History: request.History, Query: request.Query, Images: request.Images, Documents: request.Documents, Tools: request.Tools, ToolMessages: request.ToolMessages})
+	}
+	if usage.CompletionTokens == 0 {
+		usage.CompletionTokens = EstimateTextTokens(output)
+	}
+	return usage
+}
@@ -4,102 +4,280 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
 	"github.com/ingo-eichhorst/arch-bench/internal/core/ports"
 	"github.com/sashabaranov/go-openai"
 )
 
-type PriceEntry struct {
-	InputCostPerMillion  float64
-	OutputCostPerMillion float64
-}
-
-var ModelPriceMap = map[string]PriceEntry{
-	"gpt-4":                {InputCostPerMillion: 30.0, OutputCostPerMillion: 60.0},
-	"gpt-4-32k":            {InputCostPerMillion: 60.0, OutputCostPerMillion: 120.0},
-	"gpt-4-turbo":          {InputCostPerMillion: 10.0, OutputCostPerMillion: 30.0},
-	"gpt-4-vision-preview": {InputCostPerMillion: 10.0, OutputCostPerMillion: 30.0},
-	"gpt-4o":               {InputCostPerMillion: 2.5, OutputCostPerMillion: 10.0},
-	"gpt-4o-mini":          {InputCostPerMillion: 0.15, OutputCostPerMillion: 0.6},
-	"o1":                   {InputCostPerMillion: 15.0, OutputCostPerMillion: 60.0},
-	"o1-preview":           {InputCostPerMillion: 15.0, OutputCostPerMillion: 60.0},
-	"o1-mini":              {InputCostPerMillion: 3.0, OutputCostPerMillion: 12.0},
-}
-
 type OpenAIProvider struct {
 	client         *openai.Client
 	model          string
+	pricing        *domain.PricingCatalog
 	costCalculator CostCalculator
 }
 
-// CostCalculator interface defines the contract for calculating costs.  This allows for easier mocking and swapping of cost calculation logic.
-type CostCalculator interface {
-	CalculateCost(promptTokens int, completionTokens int, model string) (float64, error)
-}
-
-type MapBasedCostCalculator struct{}
+// OpenAIOption configures the OpenAI provider.
+type OpenAIOption func(*openai.ClientConfig)
 
-func (c *MapBasedCostCalculator) CalculateCost(promptTokens, completionTokens int, model string) (float64, error) {
-	price, ok := ModelPriceMap[model]
-	if !ok {
-		return 0, fmt.Errorf("model not found in price map: %s", model)
+// WithBaseURL points the provider to an OpenAI compatible API, e.g. a proxy or a local fake server.
+func WithBaseURL(baseURL string) OpenAIOption {
+	return func(c *openai.ClientConfig) {
+		if baseURL != "" {
+			c.BaseURL = baseURL
+		}
 	}
-
-	promptCost := (float64(promptTokens) / 1000000) * price.InputCostPerMillion
-	completionCost := (float64(completionTokens) / 1000000) * price.OutputCostPerMillion
-
-	return promptCost + completionCost, nil
 }
 
-func NewOpenAIProvider(apiKey, model string) ports.LLMProvider {
-	client := openai.NewClient(apiKey)
+func NewOpenAIProvider(apiKey, model string, pricing *domain.PricingCatalog, opts ...OpenAIOption) ports.LLMProvider {
+	clientConfig := openai.DefaultConfig(apiKey)
+	clientConfig.HTTPClient = &http.Client{Transport: &apiTransport{base: http.DefaultTransport}}
+	for _, opt := range opts {
+		opt(&clientConfig)
+	}
 	return &OpenAIProvider{
-		client:         client,
+		client:         openai.NewClientWithConfig(clientConfig),
 		model:          model,
-		costCalculator: &MapBasedCostCalculator{},
+		pricing:        pricing,
+		costCalculator: NewCatalogCostCalculator(pricing, "openai"),
 	}
 }
 
-func (p *OpenAIProvider) GenerateResponse(systemPrompt string, query string, images []string) (domain.LLMResponse, error) {
-	resp, err := p.client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: p.model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: query,
-				},
-			},
-		},
-	)
+func (p *OpenAIProvider) GenerateResponse(ctx context.Context, request domain.LLMRequest) (domain.LLMResponse, error) {
+	chatRequest, extraFields, applied := p.newChatCompletionRequest(request)
+
+	ctx, info := withResponseInfo(withExtraFields(ctx, extraFields))
+	resp, err := p.client.CreateChatCompletion(ctx, chatRequest)
 
 	if err != nil {
-		return domain.LLMResponse{}, fmt.Errorf("error calling OpenAI API: %v", err)
+		return domain.LLMResponse{}, fmt.Errorf("error calling OpenAI API: %w", newOpenAIError(err, info))
 	}
 
-	cost, err := p.costCalculator.CalculateCost(resp.Usage.PromptTokens, resp.Usage.CompletionTokens, p.model)
+	usage := tokenUsage(resp.Usage)
+	cost, costUnknown, err := calculateCost(p.costCalculator, "openai", usage, p.model)
 	if err != nil {
 		return domain.LLMResponse{}, fmt.Errorf("error calculating cost: %v", err)
 	}
 
+	var toolCalls []domain.ToolCall
+	for _, toolCall := range resp.Choices[0].Message.ToolCalls {
+		toolCalls = append(toolCalls, domain.ToolCall{
+			ID:        toolCall.ID,
+			Name:      toolCall.Function.Name,
+			Arguments: toolCall.Function.Arguments,
+		})
+	}
+
 	return domain.LLMResponse{
-		Response: resp.Choices[0].Message.Content,
-		Cost:     cost,
+		Response:    resp.Choices[0].Message.Content,
+		Cost:        cost,
+		CostUnknown: costUnknown,
+		Usage:       usage,
+		Generation:  applied,
+		ToolCalls:   toolCalls,
 	}, nil
 }
 
-func (p *OpenAIProvider) GetModels() []string {
-	models := make([]string, 0, len(ModelPriceMap))
-	for model := range ModelPriceMap {
-		models = append(models, model)
+// newChatCompletionRequest maps the request to the OpenAI API. Parameters the model does not support
+// are dropped, the returned generation config holds the values that were actually sent.
+func (p *OpenAIProvider) newChatCompletionRequest(request domain.LLMRequest) (openai.ChatCompletionRequest, map[string]interface{}, domain.GenerationConfig) {
+	var messages []openai.ChatCompletionMessage
+	if request.SystemPrompt != "" {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: request.SystemPrompt,
+		})
+	}
+	for _, message := range request.History {
+		messages = append(messages, chatMessage(message))
+	}
+
+	userMessage := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser}
+	if len(request.Images) == 0 {
+		userMessage.Content = request.Prompt()
+	} else {
+		userMessage.MultiContent = []openai.ChatMessagePart{
+			{Type: openai.ChatMessagePartTypeText, Text: request.Prompt()},
+		}
+		for _, image := range request.Images {
+			userMessage.MultiContent = append(userMessage.MultiContent, openai.ChatMessagePart{
+				Type: openai.ChatMessagePartTypeImageURL,
+				ImageURL: &openai.ChatMessageImageURL{
+					URL: fmt.Sprintf("data:%s;base64,%s", image.MimeType, image.Data),
+				},
+			})
+		}
+	}
+	userIndex := len(messages)
+	messages = append(messages, userMessage)
+	for _, message := range request.ToolMessages {
+		messages = append(messages, chatMessage(message))
+	}
+
+	chatRequest := openai.ChatCompletionRequest{
+		Model:    p.model,
+		Messages: messages,
+	}
+	for _, tool := range request.Tools {
+		chatRequest.Tools = append(chatRequest.Tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	extraFields := map[string]interface{}{}
+	if hasNativeDocuments(request.Documents) {
+		// File parts are not supported by the client library yet, so the messages are replaced
+		extraFields["messages"] = fileMessages(messages, userIndex, request.Documents)
+	}
+	var applied domain.GenerationConfig
+	generation := request.Generation
+	reasoning := isReasoningModel(p.model)
+
+	if generation.Temperature != nil && !reasoning {
+		chatRequest.Temperature = *generation.Temperature
+		// The client omits zero values, so an explicit 0 has to be added to the request body
+		extraFields["temperature"] = *generation.Temperature
+		applied.Temperature = generation.Temperature
+	}
+	if generation.TopP != nil && !reasoning {
+		chatRequest.TopP = *generation.TopP
+		extraFields["top_p"] = *generation.TopP
+		applied.TopP = generation.TopP
+	}
+	if generation.MaxTokens != nil {
+		if reasoning {
+			chatRequest.MaxCompletionTokens = *generation.MaxTokens
+		} else {
+			chatRequest.MaxTokens = *generation.MaxTokens
+		}
+		applied.MaxTokens = generation.MaxTokens
+	}
+	if generation.Seed != nil {
+		chatRequest.Seed = generation.Seed
+		applied.Seed = generation.Seed
+	}
+	if len(generation.Stop) > 0 && !reasoning {
+		chatRequest.Stop = generation.Stop
+		applied.Stop = generation.Stop
+	}
+	if generation.ReasoningEffort != "" && reasoning {
+		// Not supported by the client library yet, so it is added to the request body directly
+		extraFields["reasoning_effort"] = generation.ReasoningEffort
+		applied.ReasoningEffort = generation.ReasoningEffort
+	}
+
+	return chatRequest, extraFields, applied
+}
+
+func hasNativeDocuments(documents []domain.Document) bool {
+	for _, document := range documents {
+		if document.Native() {
+			return true
+		}
 	}
-	return models
+	return false
+}
+
+// chatMessage maps a previous message of the conversation or of the tool loop.
+func chatMessage(message domain.Message) openai.ChatCompletionMessage {
+	chatMessage := openai.ChatCompletionMessage{
+		Role:       message.Role,
+		Content:    message.Content,
+		ToolCallID: message.ToolCallID,
+	}
+	for _, toolCall := range message.ToolCalls {
+		chatMessage.ToolCalls = append(chatMessage.ToolCalls, openai.ToolCall{
+			ID:   toolCall.ID,
+			Type: openai.ToolTypeFunction,
+			Function: openai.FunctionCall{
+				Name:      toolCall.Name,
+				Arguments: toolCall.Arguments,
+			},
+		})
+	}
+	return chatMessage
+}
+
+// fileMessages returns the messages as request body with the native documents attached to the
+// user message at userIndex as file parts.
+func fileMessages(messages []openai.ChatCompletionMessage, userIndex int, documents []domain.Document) []interface{} {
+	var bodyMessages []interface{}
+	for i, message := range messages {
+		if i != userIndex {
+			bodyMessages = append(bodyMessages, message)
+			continue
+		}
+
+		var parts []map[string]interface{}
+		if message.MultiContent == nil {
+			parts = append(parts, map[string]interface{}{"type": "text", "text": message.Content})
+		}
+		for _, part := range message.MultiContent {
+			if part.Type == openai.ChatMessagePartTypeImageURL {
+				parts = append(parts, map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": part.ImageURL.URL}})
+			} else {
+				parts = append(parts, map[string]interface{}{"type": "text", "text": part.Text})
+			}
+		}
+		for _, document := range documents {
+			if !document.Native() {
+				continue
+			}
+			parts = append(parts, map[string]interface{}{
+				"type": "file",
+				"file": map[string]interface{}{
+					"filename":  document.Name,
+					"file_data": fmt.Sprintf("data:%s;base64,%s", document.MimeType, document.Data),
+				},
+			})
+		}
+		bodyMessages = append(bodyMessages, map[string]interface{}{"role": message.Role, "content": parts})
+	}
+	return bodyMessages
+}
+
+// supportsPDF reports whether the model takes PDF files as input.
+func supportsPDF(model string) bool {
+	return model == "o1" || strings.HasPrefix(model, "gpt-4o") || strings.HasPrefix(model, "gpt-4.1")
+}
+
+func tokenUsage(usage openai.Usage) domain.TokenUsage {
+	tokenUsage := domain.TokenUsage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+	}
+	if usage.PromptTokensDetails != nil {
+		tokenUsage.CachedTokens = usage.PromptTokensDetails.CachedTokens
+	}
+	if usage.CompletionTokensDetails != nil {
+		tokenUsage.ReasoningTokens = usage.CompletionTokensDetails.ReasoningTokens
+	}
+	return tokenUsage
+}
+
+// isReasoningModel reports whether the model is an o-series reasoning model. These models take
+// max_completion_tokens and reasoning_effort but no temperature, top_p or stop.
+func isReasoningModel(model string) bool {
+	for _, prefix := range []string{"o1", "o3", "o4"} {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *OpenAIProvider) GetModels() []string {
+	return p.pricing.Models("openai")
 }
 
 // GenerateStructuredResponse generates a response with structured output based on a JSON schema.
-func (p *OpenAIProvider) GenerateStructuredResponse(systemPrompt, query string, schema domain.StructuredOutput) (map[string]interface{}, error) {
+func (p *OpenAIProvider) GenerateStructuredResponse(ctx context.Context, request domain.LLMRequest, schema domain.StructuredOutput) (domain.StructuredResponse, error) {
 
 	// convert schema jsonstring to openai.ChatCompletionResponseFormat
 	responseFormat := openai.ChatCompletionResponseFormat{
@@ -112,39 +290,39 @@ func (p *OpenAIProvider) GenerateStructuredResponse(systemPrompt, query string,
 		},
 	}
 
-	resp, err := p.client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: p.model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: systemPrompt,
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: query,
-				},
-			},
-			ResponseFormat: &responseFormat,
-		},
-	)
+	chatRequest, extraFields, _ := p.newChatCompletionRequest(request)
+	chatRequest.ResponseFormat = &responseFormat
+
+	ctx, info := withResponseInfo(withExtraFields(ctx, extraFields))
+	resp, err := p.client.CreateChatCompletion(ctx, chatRequest)
 	if err != nil {
-		return nil, fmt.Errorf("error calling OpenAI API: %v", err)
+		return domain.StructuredResponse{}, fmt.Errorf("error calling OpenAI API: %w", newOpenAIError(err, info))
 	}
 
-	// Parse the JSON response.  Error handling is crucial here.
-	var data map[string]interface{}
-	err = json.Unmarshal([]byte(resp.Choices[0].Message.Content), &data)
+	data, err := decodeStructuredContent(resp.Choices[0].Message.Content)
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling JSON response: %v", err)
+		return domain.StructuredResponse{}, err
 	}
 
-	cost, err := p.costCalculator.CalculateCost(resp.Usage.PromptTokens, resp.Usage.CompletionTokens, p.model)
+	usage := tokenUsage(resp.Usage)
+	cost, costUnknown, err := calculateCost(p.costCalculator, "openai", usage, p.model)
 	if err != nil {
-		return nil, fmt.Errorf("error calculating cost: %v", err)
+		return domain.StructuredResponse{}, fmt.Errorf("error calculating cost: %v", err)
 	}
 
-	data["cost"] = cost
+	return domain.StructuredResponse{
+		Data:        data,
+		Cost:        cost,
+		CostUnknown: costUnknown,
+		Usage:       usage,
+	}, nil
+}
+
+// decodeStructuredContent parses the content of a structured response, the JSON object of the schema.
+func decodeStructuredContent(content string) (map[string]interface{}, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &data); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON response: %v", err)
+	}
 	return data, nil
 }
@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+func TestNewChatCompletionRequestDropsUnsupportedParameters(t *testing.T) {
+	temperature := float32(0)
+	generation := domain.GenerationConfig{Temperature: &temperature, Stop: []string{"END"}, ReasoningEffort: "low"}
+
+	tests := []struct {
+		model           string
+		wantStop        bool
+		wantTemperature bool
+		wantEffort      bool
+	}{
+		{model: "gpt-4o", wantStop: true, wantTemperature: true},
+		{model: "o3-mini", wantEffort: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			provider := &OpenAIProvider{model: tt.model}
+			chatRequest, extraFields, applied := provider.newChatCompletionRequest(domain.LLMRequest{Query: "q", Generation: generation})
+
+			if got := len(chatRequest.Stop) > 0; got != tt.wantStop {
+				t.Errorf("stop sent = %v, want %v", got, tt.wantStop)
+			}
+			if got := len(applied.Stop) > 0; got != tt.wantStop {
+				t.Errorf("stop applied = %v, want %v", got, tt.wantStop)
+			}
+			if _, got := extraFields["temperature"]; got != tt.wantTemperature {
+				t.Errorf("temperature sent = %v, want %v", got, tt.wantTemperature)
+			}
+			if _, got := extraFields["reasoning_effort"]; got != tt.wantEffort {
+				t.Errorf("reasoning_effort sent = %v, want %v", got, tt.wantEffort)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket that refills a fixed amount per minute. It is used for requests
+// per minute as well as tokens per minute.
+type rateLimiter struct {
+	mu         sync.Mutex
+	perMinute  float64
+	available  float64
+	lastRefill time.Time
+}
+
+// newRateLimiter returns nil for a limit <= 0, a nil limiter never blocks.
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		perMinute:  float64(perMinute),
+		available:  float64(perMinute),
+		lastRefill: time.Now(),
+	}
+}
+
+// Wait blocks until n units are available and takes them. Requests larger than the limit only wait for a full bucket.
+func (l *rateLimiter) Wait(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+
+	for {
+		l.mu.Lock()
+		l.refill(time.Now())
+		needed := minFloat(float64(n), l.perMinute)
+		if l.available >= needed {
+			l.available -= float64(n)
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((needed - l.available) / l.perMinute * float64(time.Minute))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Adjust corrects a previous Wait once the actual amount is known, e.g. the tokens reported by the provider.
+func (l *rateLimiter) Adjust(estimated, actual int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.available += float64(estimated - actual)
+}
+
+func (l *rateLimiter) refill(now time.Time) {
+	elapsed := now.Sub(l.lastRefill)
+	l.available = minFloat(l.perMinute, l.available+elapsed.Minutes()*l.perMinute)
+	l.lastRefill = now
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,197 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/ports"
+)
+
+// ErrNoFixture is returned in replay mode for a request that was not recorded.
+var ErrNoFixture = errors.New("no recorded fixture for request")
+
+// ReplayProvider records the requests and responses of a provider as fixtures, or serves the
+// responses from the fixtures without calling any provider, so benchmarks can run offline.
+type ReplayProvider struct {
+	provider     ports.LLMProvider
+	dir          string
+	providerName string
+	model        string
+	mode         domain.ReplayMode
+}
+
+// NewReplayProvider creates a replay provider. In replay mode provider is not used and may be nil.
+func NewReplayProvider(provider ports.LLMProvider, dir, providerName, model string, mode domain.ReplayMode) ports.LLMProvider {
+	return &ReplayProvider{
+		provider:     provider,
+		dir:          dir,
+		providerName: providerName,
+		model:        model,
+		mode:         mode,
+	}
+}
+
+// fixture is a recorded request and response. The request is stored readable, images and documents
+// by their hash. A synthetic fixture was written by hand instead of recorded, its response and usage
+// are made up and it has no recording time.
+type fixture struct {
+	Key                string                     `json:"key"`
+	Provider           string                     `json:"provider"`
+	Model              string                     `json:"model"`
+	Sample             int                        `json:"sample"`
+	Synthetic          bool                       `json:"synthetic,omitempty"`
+	RecordedAt         *time.Time                 `json:"recorded_at,omitempty"`
+	SystemPrompt       string                     `json:"system_prompt,omitempty"`
+	History            []domain.Message           `json:"history,omitempty"`
+	Query              string                     `json:"query"`
+	Images             []string                   `json:"images,omitempty"`
+	Documents          []string                   `json:"documents,omitempty"`
+	Tools              []string                   `json:"tools,omitempty"`
+	ToolMessages       []domain.Message           `json:"tool_messages,omitempty"`
+	Generation         domain.GenerationConfig    `json:"generation"`
+	Schema             *domain.StructuredOutput   `json:"schema,omitempty"`
+	Response           *domain.LLMResponse        `json:"response,omitempty"`
+	StructuredResponse *domain.StructuredResponse `json:"structured_response,omitempty"`
+}
+
+func (p *ReplayProvider) GenerateResponse(ctx context.Context, request domain.LLMRequest) (domain.LLMResponse, error) {
+	f, err := p.newFixture(ctx, request, nil)
+	if err != nil {
+		return domain.LLMResponse{}, err
+	}
+
+	if p.mode == domain.ReplayModeReplay {
+		recorded, err := p.read(f)
+		if err != nil {
+			return domain.LLMResponse{}, err
+		}
+		if recorded.Response == nil {
+			return domain.LLMResponse{}, fmt.Errorf("fixture %s has no text response", p.path(f.Key))
+		}
+		return *recorded.Response, nil
+	}
+
+	response, err := p.provider.GenerateResponse(ctx, request)
+	if err != nil {
+		return domain.LLMResponse{}, err
+	}
+	f.Response = &response
+	return response, p.write(f)
+}
+
+func (p *ReplayProvider) GenerateStructuredResponse(ctx context.Context, request domain.LLMRequest, schema domain.StructuredOutput) (domain.StructuredResponse, error) {
+	f, err := p.newFixture(ctx, request, &schema)
+	if err != nil {
+		return domain.StructuredResponse{}, err
+	}
+
+	if p.mode == domain.ReplayModeReplay {
+		recorded, err := p.read(f)
+		if err != nil {
+			return domain.StructuredResponse{}, err
+		}
+		if recorded.StructuredResponse == nil {
+			return domain.StructuredResponse{}, fmt.Errorf("fixture %s has no structured response", p.path(f.Key))
+		}
+		return *recorded.StructuredResponse, nil
+	}
+
+	response, err := p.provider.GenerateStructuredResponse(ctx, request, schema)
+	if err != nil {
+		return domain.StructuredResponse{}, err
+	}
+	f.StructuredResponse = &response
+	return response, p.write(f)
+}
+
+func (p *ReplayProvider) GetModels() []string {
+	if p.provider == nil {
+		return []string{p.model}
+	}
+	return p.provider.GetModels()
+}
+
+func (p *ReplayProvider) newFixture(ctx context.Context, request domain.LLMRequest, schema *domain.StructuredOutput) (fixture, error) {
+	sample := cacheSample(ctx)
+	key, err := requestKey(p.providerName, p.model, sample, request, schema)
+	if err != nil {
+		return fixture{}, err
+	}
+
+	images := make([]string, len(request.Images))
+	for i, image := range request.Images {
+		hash := sha256.Sum256([]byte(image.Data))
+		images[i] = image.MimeType + ";sha256:" + hex.EncodeToString(hash[:])
+	}
+	documents := make([]string, len(request.Documents))
+	for i, document := range request.Documents {
+		hash := sha256.Sum256([]byte(document.Data + document.Text))
+		documents[i] = document.Name + ";sha256:" + hex.EncodeToString(hash[:])
+	}
+
+	tools := make([]string, len(request.Tools))
+	for i, tool := range request.Tools {
+		tools[i] = tool.Name
+	}
+
+	return fixture{
+		Key:          key,
+		Provider:     p.providerName,
+		Model:        p.model,
+		Sample:       sample,
+		SystemPrompt: request.SystemPrompt,
+		History:      request.History,
+		Query:        request.Query,
+		Images:       images,
+		Documents:    documents,
+		Tools:        tools,
+		ToolMessages: request.ToolMessages,
+		Generation:   request.Generation,
+		Schema:       schema,
+	}, nil
+}
+
+func (p *ReplayProvider) path(key string) string {
+	return filepath.Join(p.dir, p.providerName, p.model, key+".json")
+}
+
+func (p *ReplayProvider) read(f fixture) (fixture, error) {
+	data, err := os.ReadFile(p.path(f.Key))
+	if os.IsNotExist(err) {
+		return fixture{}, fmt.Errorf("%w: %s/%s sample %d, query %q (record the fixtures with --replay record)", ErrNoFixture, p.providerName, p.model, f.Sample, truncate(f.Query, 60))
+	}
+	if err != nil {
+		return fixture{}, fmt.Errorf("error reading fixture: %w", err)
+	}
+
+	var recorded fixture
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return fixture{}, fmt.Errorf("error parsing fixture %s: %w", p.path(f.Key), err)
+	}
+	return recorded, nil
+}
+
+func (p *ReplayProvider) write(f fixture) error {
+	recordedAt := time.Now()
+	f.RecordedAt = &recordedAt
+	if err := writeJSONFile(p.path(f.Key), f); err != nil {
+		return fmt.Errorf("error writing fixture: %w", err)
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
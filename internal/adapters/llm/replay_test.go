@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+// countingProvider answers with fixed responses and counts the calls.
+type countingProvider struct {
+	calls int
+}
+
+func (p *countingProvider) GenerateResponse(ctx context.Context, request domain.LLMRequest) (domain.LLMResponse, error) {
+	p.calls++
+	return domain.LLMResponse{Response: "recorded", Cost: 0.01}, nil
+}
+
+func (p *countingProvider) GenerateStructuredResponse(ctx context.Context, request domain.LLMRequest, schema domain.StructuredOutput) (domain.StructuredResponse, error) {
+	p.calls++
+	return domain.StructuredResponse{Data: map[string]interface{}{"score": 70.0}}, nil
+}
+
+func (p *countingProvider) GetModels() []string {
+	return []string{"model"}
+}
+
+func TestReplayProviderRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	request := domain.LLMRequest{Query: "Describe the architecture."}
+	schema := domain.StructuredOutput{Type: "object", Required: []string{"score"}}
+	ctx := WithCacheSample(context.Background(), 1)
+
+	provider := &countingProvider{}
+	recorder := NewReplayProvider(provider, dir, "openai", "gpt-4o", domain.ReplayModeRecord)
+	if _, err := recorder.GenerateResponse(ctx, request); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if _, err := recorder.GenerateStructuredResponse(ctx, request, schema); err != nil {
+		t.Fatalf("record structured: %v", err)
+	}
+	fixtures, _ := filepath.Glob(filepath.Join(dir, "openai", "gpt-4o", "*.json"))
+	if provider.calls != 2 || len(fixtures) != 2 {
+		t.Fatalf("got %d calls and %d fixtures, want 2 and 2", provider.calls, len(fixtures))
+	}
+
+	replayer := NewReplayProvider(nil, dir, "openai", "gpt-4o", domain.ReplayModeReplay)
+	response, err := replayer.GenerateResponse(ctx, request)
+	if err != nil || response.Response != "recorded" || response.Cost != 0.01 {
+		t.Errorf("replay = %+v, %v, want the recorded response", response, err)
+	}
+	structured, err := replayer.GenerateStructuredResponse(ctx, request, schema)
+	if err != nil || structured.Data["score"] != 70.0 {
+		t.Errorf("replay structured = %+v, %v, want the recorded response", structured, err)
+	}
+}
+
+func TestReplayProviderMissingFixture(t *testing.T) {
+	dir := t.TempDir()
+	recorder := NewReplayProvider(&countingProvider{}, dir, "openai", "gpt-4o", domain.ReplayModeRecord)
+	if _, err := recorder.GenerateResponse(WithCacheSample(context.Background(), 1), domain.LLMRequest{Query: "q"}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	replayer := NewReplayProvider(nil, dir, "openai", "gpt-4o", domain.ReplayModeReplay)
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		request domain.LLMRequest
+	}{
+		{name: "other query", ctx: WithCacheSample(context.Background(), 1), request: domain.LLMRequest{Query: "other"}},
+		{name: "other sample", ctx: WithCacheSample(context.Background(), 2), request: domain.LLMRequest{Query: "q"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := replayer.GenerateResponse(tt.ctx, tt.request); !errors.Is(err, ErrNoFixture) {
+				t.Errorf("error = %v, want ErrNoFixture", err)
+			}
+		})
+	}
+
+}
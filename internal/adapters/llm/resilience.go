@@ -0,0 +1,215 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/ports"
+)
+
+// estimatedImageTokens is used for rate limiting when the image size is unknown (a 1024x1024 image).
+const estimatedImageTokens = 765
+
+// Resilience holds the rate limiters and the circuit breaker of a provider. It is shared by all
+// ResilientProviders of the same provider so the limits apply across models and test cases.
+type Resilience struct {
+	config      domain.ResilienceConfig
+	callTimeout time.Duration
+	requests    *rateLimiter
+	tokens      *rateLimiter
+	breaker     *circuitBreaker
+}
+
+// NewResilience creates the shared state of a provider. A positive callTimeout limits every single
+// attempt, so a hung request is cancelled and retried.
+func NewResilience(config domain.ResilienceConfig, callTimeout time.Duration) *Resilience {
+	config = config.WithDefaults()
+	return &Resilience{
+		config:      config,
+		callTimeout: callTimeout,
+		requests:    newRateLimiter(config.RequestsPerMinute),
+		tokens:      newRateLimiter(config.TokensPerMinute),
+		breaker:     newCircuitBreaker(config.CircuitBreakerThreshold, config.CircuitBreakerCooldown()),
+	}
+}
+
+// ResilientProvider decorates a provider with rate limiting, retries with exponential backoff and
+// jitter, and a circuit breaker.
+type ResilientProvider struct {
+	provider   ports.LLMProvider
+	resilience *Resilience
+}
+
+func NewResilientProvider(provider ports.LLMProvider, resilience *Resilience) ports.LLMProvider {
+	return &ResilientProvider{provider: provider, resilience: resilience}
+}
+
+func (p *ResilientProvider) GenerateResponse(ctx context.Context, request domain.LLMRequest) (domain.LLMResponse, error) {
+	var response domain.LLMResponse
+	err := p.resilience.do(ctx, estimateRequestTokens(request), func(ctx context.Context) (int, error) {
+		var err error
+		response, err = p.provider.GenerateResponse(ctx, request)
+		return response.Usage.TotalTokens(), err
+	})
+	return response, err
+}
+
+func (p *ResilientProvider) GenerateStructuredResponse(ctx context.Context, request domain.LLMRequest, schema domain.StructuredOutput) (domain.StructuredResponse, error) {
+	var response domain.StructuredResponse
+	err := p.resilience.do(ctx, estimateRequestTokens(request), func(ctx context.Context) (int, error) {
+		var err error
+		response, err = p.provider.GenerateStructuredResponse(ctx, request, schema)
+		return response.Usage.TotalTokens(), err
+	})
+	return response, err
+}
+
+func (p *ResilientProvider) GetModels() []string {
+	return p.provider.GetModels()
+}
+
+// do runs the call within the rate limits and retries it on retryable errors. The call returns the
+// tokens it actually used to correct the token rate limiter. Nothing is retried once ctx is done.
+func (r *Resilience) do(ctx context.Context, estimatedTokens int, call func(ctx context.Context) (int, error)) error {
+	for attempt := 0; ; attempt++ {
+		err := r.try(ctx, estimatedTokens, call)
+		if err == nil {
+			return nil
+		}
+		apiErr, retryable := retryableError(ctx, err)
+		if !retryable {
+			return err
+		}
+
+		if attempt >= r.config.MaxRetries {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+		}
+
+		delay := r.backoff(attempt, apiErr.RetryAfter)
+		fmt.Printf("Retrying in %s (attempt %d/%d): %v\n", delay.Round(time.Millisecond), attempt+2, r.config.MaxRetries+1, err)
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// try makes a single attempt within the circuit breaker and the rate limits. Success and retryable
+// failures are recorded with the circuit breaker, a trial call that ends otherwise is released.
+func (r *Resilience) try(ctx context.Context, estimatedTokens int, call func(ctx context.Context) (int, error)) error {
+	trial, err := r.breaker.Allow()
+	if err != nil {
+		return err
+	}
+	recorded := false
+	defer func() {
+		if trial && !recorded {
+			r.breaker.Release()
+		}
+	}()
+
+	if err := r.requests.Wait(ctx, 1); err != nil {
+		return err
+	}
+	if err := r.tokens.Wait(ctx, estimatedTokens); err != nil {
+		return err
+	}
+
+	usedTokens, err := r.attempt(ctx, call)
+	r.tokens.Adjust(estimatedTokens, usedTokens)
+	if err == nil {
+		r.breaker.RecordSuccess()
+		recorded = true
+		return nil
+	}
+	if _, retryable := retryableError(ctx, err); retryable {
+		r.breaker.RecordFailure()
+		recorded = true
+	}
+	return err
+}
+
+// retryableError returns the APIError of a failed call that can be retried. Nothing is retried once
+// ctx is done, and invalid requests say nothing about the health of the provider.
+func retryableError(ctx context.Context, err error) (*APIError, bool) {
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.Retryable() {
+		return nil, false
+	}
+	return apiErr, true
+}
+
+func (r *Resilience) attempt(ctx context.Context, call func(ctx context.Context) (int, error)) (int, error) {
+	if r.callTimeout <= 0 {
+		return call(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.callTimeout)
+	defer cancel()
+	return call(ctx)
+}
+
+// backoff returns the delay before the next attempt: the Retry-After of the provider if it sent one,
+// otherwise an exponential backoff with jitter between half and the full delay.
+func (r *Resilience) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	delay := r.config.InitialBackoff() << attempt
+	if delay <= 0 || delay > r.config.MaxBackoff() {
+		delay = r.config.MaxBackoff()
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// estimateRequestTokens approximates the tokens of a request for the token rate limiter.
+func estimateRequestTokens(request domain.LLMRequest) int {
+	tokens := EstimateTextTokens(request.SystemPrompt) + EstimateTextTokens(request.Prompt())
+	for _, message := range slices.Concat(request.History, request.ToolMessages) {
+		tokens += EstimateTextTokens(message.Content)
+		for _, toolCall := range message.ToolCalls {
+			tokens += EstimateTextTokens(toolCall.Name + toolCall.Arguments)
+		}
+	}
+	for _, tool := range request.Tools {
+		// The definitions are sent as JSON schema
+		definition, _ := json.Marshal(tool)
+		tokens += EstimateTextTokens(string(definition))
+	}
+	for _, image := range request.Images {
+		if image.Width > 0 && image.Height > 0 {
+			tokens += EstimateImageTokens(image.Width, image.Height)
+		} else {
+			tokens += estimatedImageTokens
+		}
+	}
+	for _, document := range request.Documents {
+		if document.Native() {
+			// Approximated by the extracted text, the text of the other documents is in the prompt
+			tokens += EstimateTextTokens(document.Text)
+		}
+	}
+	if request.Generation.MaxTokens != nil {
+		tokens += *request.Generation.MaxTokens
+	}
+	return tokens
+}
@@ -0,0 +1,274 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/ports"
+)
+
+const chatCompletionBody = `{"id": "chatcmpl-1", "object": "chat.completion", "model": "gpt-4o",
+	"choices": [{"index": 0, "message": {"role": "assistant", "content": "ok"}, "finish_reason": "stop"}],
+	"usage": {"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12}}`
+
+// fakeOpenAI serves the chat completions API with the handler and counts the requests.
+func fakeOpenAI(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, request int64)) (string, *atomic.Int64) {
+	t.Helper()
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r, requests.Add(1))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/v1", &requests
+}
+
+func writeCompletion(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, chatCompletionBody)
+}
+
+func writeError(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"error": {"message": "status %d", "type": "test"}}`, status)
+}
+
+func newTestProvider(baseURL string, resilience *Resilience) ports.LLMProvider {
+	pricing := &domain.PricingCatalog{Prices: []domain.ModelPrice{
+		{Provider: "openai", Model: "gpt-4o", InputCostPerMillion: 2.5, OutputCostPerMillion: 10, EffectiveDate: "2024-01-01"},
+	}}
+	return NewResilientProvider(NewOpenAIProvider("test-key", "gpt-4o", pricing, WithBaseURL(baseURL)), resilience)
+}
+
+func TestResilienceRetryAfter(t *testing.T) {
+	baseURL, requests := fakeOpenAI(t, func(w http.ResponseWriter, r *http.Request, request int64) {
+		if request == 1 {
+			w.Header().Set("Retry-After", "0.1")
+			writeError(w, http.StatusTooManyRequests)
+			return
+		}
+		writeCompletion(w)
+	})
+	// The backoff is far longer than the Retry-After, so a quick retry shows the header was used
+	resilience := NewResilience(domain.ResilienceConfig{InitialBackoffMs: 10000, MaxBackoffMs: 10000}, 0)
+
+	started := time.Now()
+	response, err := newTestProvider(baseURL, resilience).GenerateResponse(context.Background(), domain.LLMRequest{Query: "q"})
+	elapsed := time.Since(started)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if response.Response != "ok" || requests.Load() != 2 {
+		t.Errorf("got %q after %d requests, want ok after 2", response.Response, requests.Load())
+	}
+	if elapsed < 100*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("retried after %s, want the Retry-After of 100ms", elapsed)
+	}
+}
+
+func TestResilienceRetriesServerErrors(t *testing.T) {
+	baseURL, requests := fakeOpenAI(t, func(w http.ResponseWriter, r *http.Request, request int64) {
+		if request <= 2 {
+			writeError(w, http.StatusServiceUnavailable)
+			return
+		}
+		writeCompletion(w)
+	})
+	resilience := NewResilience(domain.ResilienceConfig{InitialBackoffMs: 1, MaxBackoffMs: 5}, 0)
+
+	response, err := newTestProvider(baseURL, resilience).GenerateResponse(context.Background(), domain.LLMRequest{Query: "q"})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if response.Response != "ok" || requests.Load() != 3 {
+		t.Errorf("got %q after %d requests, want ok after 3", response.Response, requests.Load())
+	}
+}
+
+func TestResilienceGivesUpAfterMaxRetries(t *testing.T) {
+	baseURL, requests := fakeOpenAI(t, func(w http.ResponseWriter, r *http.Request, request int64) {
+		writeError(w, http.StatusInternalServerError)
+	})
+	resilience := NewResilience(domain.ResilienceConfig{MaxRetries: 2, InitialBackoffMs: 1, MaxBackoffMs: 5}, 0)
+
+	_, err := newTestProvider(baseURL, resilience).GenerateResponse(context.Background(), domain.LLMRequest{Query: "q"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("error = %v, want an APIError with status 500", err)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("got %d requests, want 3", requests.Load())
+	}
+}
+
+func TestResilienceDoesNotRetryInvalidRequests(t *testing.T) {
+	baseURL, requests := fakeOpenAI(t, func(w http.ResponseWriter, r *http.Request, request int64) {
+		writeError(w, http.StatusBadRequest)
+	})
+	resilience := NewResilience(domain.ResilienceConfig{InitialBackoffMs: 1, MaxBackoffMs: 5, CircuitBreakerThreshold: 1}, 0)
+	provider := newTestProvider(baseURL, resilience)
+
+	for i := 0; i < 2; i++ {
+		_, err := provider.GenerateResponse(context.Background(), domain.LLMRequest{Query: "q"})
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+			t.Fatalf("error = %v, want an APIError with status 400", err)
+		}
+	}
+	// Invalid requests are neither retried nor open the circuit
+	if requests.Load() != 2 {
+		t.Errorf("got %d requests, want 2", requests.Load())
+	}
+}
+
+func TestResilienceCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	baseURL, requests := fakeOpenAI(t, func(w http.ResponseWriter, r *http.Request, request int64) {
+		if !healthy.Load() {
+			writeError(w, http.StatusInternalServerError)
+			return
+		}
+		writeCompletion(w)
+	})
+	resilience := NewResilience(domain.ResilienceConfig{MaxRetries: -1, CircuitBreakerThreshold: 2, CircuitBreakerCooldownMs: 50}, 0)
+	provider := newTestProvider(baseURL, resilience)
+	generate := func() error {
+		_, err := provider.GenerateResponse(context.Background(), domain.LLMRequest{Query: "q"})
+		return err
+	}
+
+	// Two failures open the circuit, the next call is rejected without a request
+	for i := 0; i < 2; i++ {
+		if err := generate(); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d = %v, want a server error", i+1, err)
+		}
+	}
+	if err := generate(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("call after threshold = %v, want ErrCircuitOpen", err)
+	}
+	if requests.Load() != 2 {
+		t.Fatalf("got %d requests, want 2", requests.Load())
+	}
+
+	// A failed trial after the cooldown opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	if err := generate(); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("trial = %v, want a server error", err)
+	}
+	if err := generate(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("call after failed trial = %v, want ErrCircuitOpen", err)
+	}
+
+	// A successful trial closes it
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := generate(); err != nil {
+			t.Fatalf("call %d after recovery: %v", i+1, err)
+		}
+	}
+	if requests.Load() != 5 {
+		t.Errorf("got %d requests, want 5", requests.Load())
+	}
+}
+
+func TestResilienceCallTimeout(t *testing.T) {
+	hung := make(chan struct{})
+	baseURL, requests := fakeOpenAI(t, func(w http.ResponseWriter, r *http.Request, request int64) {
+		if request == 1 {
+			// Hangs until the client gives up or the test ends
+			select {
+			case <-r.Context().Done():
+			case <-hung:
+			}
+			return
+		}
+		writeCompletion(w)
+	})
+	// Runs before the server is closed, which waits for the hung handler
+	t.Cleanup(func() { close(hung) })
+	resilience := NewResilience(domain.ResilienceConfig{InitialBackoffMs: 1, MaxBackoffMs: 5}, 50*time.Millisecond)
+
+	response, err := newTestProvider(baseURL, resilience).GenerateResponse(context.Background(), domain.LLMRequest{Query: "q"})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if response.Response != "ok" || requests.Load() != 2 {
+		t.Errorf("got %q after %d requests, want ok after 2", response.Response, requests.Load())
+	}
+}
+
+// TestResilienceReleasesTrial checks that a half-open trial that ends without a result does not keep
+// the circuit open for good.
+func TestResilienceReleasesTrial(t *testing.T) {
+	invalid := &APIError{Provider: "test", StatusCode: http.StatusBadRequest, Err: errors.New("invalid")}
+	tests := []struct {
+		name   string
+		config domain.ResilienceConfig
+		// exit runs the trial that ends without a result
+		exit func(r *Resilience) error
+	}{
+		{
+			name: "non-retryable error",
+			exit: func(r *Resilience) error {
+				return r.do(context.Background(), 1, func(ctx context.Context) (int, error) { return 0, invalid })
+			},
+		},
+		{
+			name: "context done during the call",
+			exit: func(r *Resilience) error {
+				ctx, cancel := context.WithCancel(context.Background())
+				return r.do(ctx, 1, func(ctx context.Context) (int, error) {
+					cancel()
+					return 0, &APIError{Provider: "test", StatusCode: http.StatusServiceUnavailable, Err: ctx.Err()}
+				})
+			},
+		},
+		{
+			name:   "request rate limit wait fails",
+			config: domain.ResilienceConfig{RequestsPerMinute: 1},
+			exit: func(r *Resilience) error {
+				r.requests.Wait(context.Background(), 1)
+				return doWithTimeout(r, 1)
+			},
+		},
+		{
+			name:   "token rate limit wait fails",
+			config: domain.ResilienceConfig{TokensPerMinute: 100},
+			exit: func(r *Resilience) error {
+				r.tokens.Wait(context.Background(), 100)
+				return doWithTimeout(r, 100)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.CircuitBreakerThreshold = 1
+			config.CircuitBreakerCooldownMs = 10
+			resilience := NewResilience(config, 0)
+			resilience.breaker.RecordFailure()
+			time.Sleep(20 * time.Millisecond)
+
+			if err := tt.exit(resilience); err == nil || errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("trial = %v, want it to fail without a result", err)
+			}
+			if _, err := resilience.breaker.Allow(); errors.Is(err, ErrCircuitOpen) {
+				t.Errorf("circuit stays open after the trial ended without a result")
+			}
+		})
+	}
+}
+
+func doWithTimeout(r *Resilience, tokens int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	return r.do(ctx, tokens, func(ctx context.Context) (int, error) { return tokens, nil })
+}
@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type extraFieldsKey struct{}
+
+type responseInfoKey struct{}
+
+// responseInfo collects details of the HTTP response that the client library does not expose.
+type responseInfo struct {
+	retryAfter time.Duration
+}
+
+// withExtraFields attaches JSON fields to the context that apiTransport merges into the request body.
+func withExtraFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, extraFieldsKey{}, fields)
+}
+
+// withResponseInfo attaches a responseInfo to the context that apiTransport fills in.
+func withResponseInfo(ctx context.Context) (context.Context, *responseInfo) {
+	info := &responseInfo{}
+	return context.WithValue(ctx, responseInfoKey{}, info), info
+}
+
+// apiTransport adds fields to JSON request bodies that the client library cannot express,
+// e.g. explicit zero values or parameters it does not know yet, and records the Retry-After header.
+type apiTransport struct {
+	base http.RoundTripper
+}
+
+func (t *apiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, err := addExtraFields(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if info, ok := req.Context().Value(responseInfoKey{}).(*responseInfo); ok {
+		info.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return resp, nil
+}
+
+func addExtraFields(req *http.Request) (*http.Request, error) {
+	fields, ok := req.Context().Value(extraFieldsKey{}).(map[string]interface{})
+	if !ok || req.Body == nil {
+		return req, nil
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading request body: %w", err)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("error unmarshalling request body: %w", err)
+	}
+	for key, value := range fields {
+		body[key] = value
+	}
+	data, err = json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling request body: %w", err)
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return req, nil
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
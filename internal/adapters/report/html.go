@@ -0,0 +1,159 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/ports"
+)
+
+const (
+	BenchmarkHTMLReportFile  = "report.html"
+	ComparisonHTMLReportFile = "comparison.html"
+)
+
+var htmlFuncs = template.FuncMap{
+	"duration": func(d time.Duration) string { return d.Round(time.Millisecond).String() },
+	"significant": func(c domain.PairedComparison) bool {
+		return c.IsSignificant(1 - domain.DefaultConfidenceLevel)
+	},
+}
+
+var benchmarkTemplate = template.Must(template.New("benchmark").Funcs(htmlFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Benchmark Results: {{.Benchmark.Name}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #eee; }
+</style>
+</head>
+<body>
+<h1>Benchmark Results: {{.Benchmark.Name}}</h1>
+<p>Run {{.Benchmark.RunID}} &middot; Eval: {{.Benchmark.EvalProvider}} / {{.Benchmark.EvalModel}} &middot; Status: {{.Benchmark.Status}}{{with .Benchmark.StopReason}} ({{.}}){{end}}{{with .Benchmark.SourceRunID}} &middot; Rescored outputs of run {{.}}{{end}}</p>
+<p>Average Rating: {{printf "%.2f" .ConfidenceInterval.Mean}} (95% CI [{{printf "%.2f" .ConfidenceInterval.Lower}}, {{printf "%.2f" .ConfidenceInterval.Upper}}], n={{.ConfidenceInterval.N}})</p>
+<p>Cost: ${{printf "%.6f" .GenerationCost}} generation + ${{printf "%.6f" .EvaluationCost}} evaluation{{if .Benchmark.CostUnknown}} (incomplete, the price of at least one model is unknown){{end}}</p>
+<p>Tokens: {{template "usage" .Usage}}</p>
+{{with .Benchmark.Errors}}<p>Errors: {{.}} failed repetitions, excluded from the ratings</p>{{end}}
+{{range .TestSuites}}
+<h2>{{.Suite.Name}} ({{.Suite.Provider}} / {{.Suite.Model}})</h2>
+<p>Average Rating: {{printf "%.2f" .ConfidenceInterval.Mean}} (95% CI [{{printf "%.2f" .ConfidenceInterval.Lower}}, {{printf "%.2f" .ConfidenceInterval.Upper}}])</p>
+<p>Tokens: {{template "usage" .Suite.Usage}}</p>
+<table>
+<tr><th>TestCase</th><th>Duration</th><th>Generation Cost</th><th>Evaluation Cost</th><th>Prompt Tokens</th><th>Completion Tokens</th><th>Rating</th><th>Reps</th><th>Errors</th><th>StdDev</th><th>Min</th><th>Max</th><th>Pass@k</th><th>Pass^k</th></tr>
+{{range .Results}}<tr><td>{{.TestCase}}</td><td>{{duration .Duration}}</td><td>${{printf "%.6f" .GenerationCost}}</td><td>${{printf "%.6f" .EvaluationCost}}</td><td>{{.Usage.PromptTokens}}</td><td>{{.Usage.CompletionTokens}}</td><td>{{printf "%.2f" .AverageRating}}</td><td>{{.Repetitions}}</td><td>{{.Errors}}</td><td>{{printf "%.2f" .Rating.StdDev}}</td><td>{{printf "%.2f" .Rating.Min}}</td><td>{{printf "%.2f" .Rating.Max}}</td><td>{{printf "%.2f" .PassAtK}}</td><td>{{printf "%.2f" .PassHatK}}</td></tr>
+{{end}}</table>
+{{if .Suite.Errors}}<table>
+<tr><th>Failed TestCase</th><th>Repetition</th><th>Category</th><th>Error</th></tr>
+{{range .Suite.TestCases}}{{$name := .Name}}{{range .Results}}{{$repetition := .Repetition}}{{with .Error}}<tr><td>{{$name}}</td><td>{{$repetition}}</td><td>{{.Category}}</td><td>{{.Message}}</td></tr>
+{{end}}{{end}}{{end}}</table>
+{{end}}{{end}}
+</body>
+</html>
+{{define "usage"}}{{.TotalTokens}} total (prompt {{.PromptTokens}}, cached {{.CachedTokens}}, completion {{.CompletionTokens}}, reasoning {{.ReasoningTokens}}){{end}}
+`))
+
+var comparisonTemplate = template.Must(template.New("comparison").Funcs(htmlFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Comparison: {{.Benchmark}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #eee; }
+tr.significant { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Comparison: {{.Benchmark}}</h1>
+<p>Baseline {{.BaselineRunID}} vs. candidate {{.CandidateRunID}}. Bold rows are significant at the 5% level.</p>
+<table>
+<tr><th>TestSuite</th><th>N</th><th>Baseline</th><th>Candidate</th><th>Diff</th><th>95% CI</th><th>p (paired bootstrap)</th><th>p (Wilcoxon)</th></tr>
+{{range .Rows}}<tr{{if significant .}} class="significant"{{end}}><td>{{.Name}}</td><td>{{.N}}</td><td>{{printf "%.2f" .BaselineMean}}</td><td>{{printf "%.2f" .CandidateMean}}</td><td>{{printf "%.2f" .MeanDifference.Mean}}</td><td>[{{printf "%.2f" .MeanDifference.Lower}}, {{printf "%.2f" .MeanDifference.Upper}}]</td><td>{{printf "%.4f" .BootstrapPValue}}</td><td>{{printf "%.4f" .WilcoxonPValue}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type HTMLReportGenerator struct {
+	outputDir string
+}
+
+func NewHTMLReportCreator(outputDir string) ports.ReportCreator {
+	return &HTMLReportGenerator{outputDir: outputDir}
+}
+
+// GenerateTestSuiteReport is a no-op, the test suites are part of the benchmark report.
+func (h *HTMLReportGenerator) GenerateTestSuiteReport(testSuite *domain.TestSuite) error {
+	return nil
+}
+
+func (h *HTMLReportGenerator) GenerateBenchmarkReport(benchmark *domain.Benchmark, testSuites []*domain.TestSuite) error {
+	type suiteView struct {
+		Suite              *domain.TestSuite
+		ConfidenceInterval domain.ConfidenceInterval
+		Results            []domain.TestSuiteResult
+	}
+
+	view := struct {
+		Benchmark          *domain.Benchmark
+		ConfidenceInterval domain.ConfidenceInterval
+		Usage              domain.TokenUsage
+		GenerationCost     float64
+		EvaluationCost     float64
+		TestSuites         []suiteView
+	}{
+		Benchmark:          benchmark,
+		ConfidenceInterval: benchmark.RatingConfidenceInterval(),
+		Usage:              benchmark.Usage(),
+		GenerationCost:     benchmark.GenerationCost(),
+		EvaluationCost:     benchmark.EvaluationCost(),
+	}
+	for _, testSuite := range testSuites {
+		view.TestSuites = append(view.TestSuites, suiteView{
+			Suite:              testSuite,
+			ConfidenceInterval: testSuite.RatingConfidenceInterval(),
+			Results:            testSuite.AggregateResults(),
+		})
+	}
+
+	return h.render(BenchmarkHTMLReportFile, benchmarkTemplate, view)
+}
+
+func (h *HTMLReportGenerator) GenerateComparisonReport(comparison *domain.Comparison) error {
+	view := struct {
+		*domain.Comparison
+		Rows []domain.PairedComparison
+	}{
+		Comparison: comparison,
+		Rows:       append(append([]domain.PairedComparison{}, comparison.TestSuites...), comparison.Overall),
+	}
+	return h.render(ComparisonHTMLReportFile, comparisonTemplate, view)
+}
+
+func (h *HTMLReportGenerator) render(fileName string, tmpl *template.Template, data interface{}) error {
+	if err := os.MkdirAll(h.outputDir, 0755); err != nil {
+		return fmt.Errorf("error creating report directory: %w", err)
+	}
+
+	path := filepath.Join(h.outputDir, fileName)
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating report %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if err := tmpl.Execute(file, data); err != nil {
+		return fmt.Errorf("error rendering report %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/ports"
+)
+
+const (
+	BenchmarkReportFile  = "report.json"
+	ComparisonReportFile = "comparison.json"
+)
+
+type JSONReportGenerator struct {
+	outputDir string
+}
+
+func NewJSONReportCreator(outputDir string) ports.ReportCreator {
+	return &JSONReportGenerator{outputDir: outputDir}
+}
+
+// GenerateTestSuiteReport is a no-op, the test suites are part of the benchmark report.
+func (j *JSONReportGenerator) GenerateTestSuiteReport(testSuite *domain.TestSuite) error {
+	return nil
+}
+
+func (j *JSONReportGenerator) GenerateBenchmarkReport(benchmark *domain.Benchmark, testSuites []*domain.TestSuite) error {
+	report := struct {
+		*domain.Benchmark
+		ConfidenceInterval           domain.ConfidenceInterval            `json:"confidence_interval"`
+		TestSuiteConfidenceIntervals map[string]domain.ConfidenceInterval `json:"test_suite_confidence_intervals"`
+		TestSuiteResults             map[string][]domain.TestSuiteResult  `json:"test_suite_results"`
+		GenerationCost               float64                              `json:"generation_cost"`
+		EvaluationCost               float64                              `json:"evaluation_cost"`
+		CostUnknown                  bool                                 `json:"cost_unknown"`
+		Errors                       int                                  `json:"errors"`
+		Usage                        domain.TokenUsage                    `json:"usage"`
+		TestSuiteUsage               map[string]domain.TokenUsage         `json:"test_suite_usage"`
+	}{
+		Benchmark:                    benchmark,
+		ConfidenceInterval:           benchmark.RatingConfidenceInterval(),
+		TestSuiteConfidenceIntervals: make(map[string]domain.ConfidenceInterval, len(testSuites)),
+		TestSuiteResults:             make(map[string][]domain.TestSuiteResult, len(testSuites)),
+		GenerationCost:               benchmark.GenerationCost(),
+		EvaluationCost:               benchmark.EvaluationCost(),
+		CostUnknown:                  benchmark.CostUnknown(),
+		Errors:                       benchmark.Errors(),
+		Usage:                        benchmark.Usage(),
+		TestSuiteUsage:               make(map[string]domain.TokenUsage, len(testSuites)),
+	}
+	for _, testSuite := range testSuites {
+		report.TestSuiteConfidenceIntervals[testSuite.Name] = testSuite.RatingConfidenceInterval()
+		report.TestSuiteUsage[testSuite.Name] = testSuite.Usage()
+		report.TestSuiteResults[testSuite.Name] = testSuite.AggregateResults()
+	}
+
+	return j.write(BenchmarkReportFile, report)
+}
+
+func (j *JSONReportGenerator) GenerateComparisonReport(comparison *domain.Comparison) error {
+	return j.write(ComparisonReportFile, comparison)
+}
+
+func (j *JSONReportGenerator) write(fileName string, v interface{}) error {
+	if err := os.MkdirAll(j.outputDir, 0755); err != nil {
+		return fmt.Errorf("error creating report directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling report: %w", err)
+	}
+
+	path := filepath.Join(j.outputDir, fileName)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing report %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadBenchmarkReport reads a benchmark run that was written by the JSON report creator.
+func LoadBenchmarkReport(path string) (*domain.Benchmark, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading report %s: %w", path, err)
+	}
+
+	var benchmark domain.Benchmark
+	if err := json.Unmarshal(data, &benchmark); err != nil {
+		return nil, fmt.Errorf("error unmarshalling report %s: %w", path, err)
+	}
+	return &benchmark, nil
+}
@@ -19,64 +19,203 @@ func (s *StdoutReportGenerator) GenerateTestSuiteReport(testSuite *domain.TestSu
 	results := testSuite.AggregateResults()
 
 	fmt.Printf("\nResults for Test Suite: %s\n", testSuite.Name)
-	fmt.Printf("%-20s %-20s %-15s %-15s %-10s\n", "TestSuite", "TestCase", "Duration", "Cost", "Rating") //Increased width for cost
-	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("%-20s %-20s %-15s %-15s %-15s %-15s %-10s %-5s %-6s %-8s %-8s %-8s %-8s %-8s\n", "TestSuite", "TestCase", "Duration", "Gen Cost", "Eval Cost", "Tokens In/Out", "Rating", "Reps", "Errors", "StdDev", "Min", "Max", "Pass@k", "Pass^k") //Increased width for cost
+	fmt.Println(strings.Repeat("-", 172))
 
 	for _, result := range results {
-		fmt.Printf("%-20s %-20s %-15s $%-15.6f %-10.2f\n", // Changed to %-15.6f
+		fmt.Printf("%-20s %-20s %-15s $%-14.6f $%-14.6f %-15s %-10.2f %-5d %-6d %-8.2f %-8.2f %-8.2f %-8.2f %-8.2f\n", // Changed to %-15.6f
 			result.TestSuite,
 			result.TestCase,
 			result.Duration.Round(time.Millisecond),
-			result.Cost,
+			result.GenerationCost,
+			result.EvaluationCost,
+			formatTokens(result.Usage),
 			result.AverageRating,
+			result.Repetitions,
+			result.Errors,
+			result.Rating.StdDev,
+			result.Rating.Min,
+			result.Rating.Max,
+			result.PassAtK,
+			result.PassHatK,
 		)
 	}
+	if testSuite.CostUnknown() {
+		fmt.Println("Warning: the cost is incomplete, the price of at least one model is unknown.")
+	}
+	for _, testCase := range testSuite.TestCases {
+		for _, result := range testCase.Results {
+			if result.Failed() {
+				fmt.Printf("Failed: %s (repetition %d) [%s] %s\n", testCase.Name, result.Repetition, result.Error.Category, result.Error.Message)
+			}
+		}
+	}
 	fmt.Println()
 	return nil
 }
 
-func (s *StdoutReportGenerator) GenerateBenchmarkReport(benchmark *domain.Benchmark, testSuites []*domain.TestSuite) {
+func (s *StdoutReportGenerator) GenerateBenchmarkReport(benchmark *domain.Benchmark, testSuites []*domain.TestSuite) error {
 	fmt.Printf("\nBenchmark Results: %s\n", benchmark.Name)
-	fmt.Printf("%-20s %-15s %-15s %-10s\n", "TestSuite", "Duration", "Cost", "Avg Rating")
-	fmt.Println(strings.Repeat("-", 60))
+	fmt.Printf("%-20s %-15s %-15s %-15s %-15s %-10s %-20s %-6s\n", "TestSuite", "Duration", "Gen Cost", "Eval Cost", "Tokens In/Out", "Avg Rating", "95% CI", "Errors")
+	fmt.Println(strings.Repeat("-", 122))
 
 	var totalBenchmarkDuration time.Duration
-	var totalBenchmarkCost float64
-	var totalBenchmarkRating float64
-	var numTestCases int
+	var totalBenchmarkGenerationCost float64
+	var totalBenchmarkEvaluationCost float64
 
 	for _, testSuite := range testSuites {
 		results := testSuite.AggregateResults()
 		var totalTestSuiteDuration time.Duration
-		var totalTestSuiteCost float64
-		var totalTestSuiteRating float64
+		var totalTestSuiteGenerationCost float64
+		var totalTestSuiteEvaluationCost float64
 
 		for _, result := range results {
 			totalTestSuiteDuration += result.Duration
-			totalTestSuiteCost += result.Cost
-			totalTestSuiteRating += result.AverageRating
-			numTestCases++
+			totalTestSuiteGenerationCost += result.GenerationCost
+			totalTestSuiteEvaluationCost += result.EvaluationCost
 		}
 
-		avgRating := totalTestSuiteRating / float64(len(results))
+		// Test cases that failed in every repetition have no rating
+		avgRating := domain.Mean(testSuite.Ratings())
 
-		fmt.Printf("%-20s %-15s $%-15.6f %-10.2f\n",
+		fmt.Printf("%-20s %-15s $%-14.6f $%-14.6f %-15s %-10.2f %-20s %-6d\n",
 			testSuite.Name,
 			totalTestSuiteDuration.Round(time.Millisecond),
-			totalTestSuiteCost,
+			totalTestSuiteGenerationCost,
+			totalTestSuiteEvaluationCost,
+			formatTokens(testSuite.Usage()),
 			avgRating,
+			formatConfidenceInterval(testSuite.RatingConfidenceInterval()),
+			testSuite.Errors(),
 		)
 
 		totalBenchmarkDuration += totalTestSuiteDuration
-		totalBenchmarkCost += totalTestSuiteCost
-		totalBenchmarkRating += totalTestSuiteRating
+		totalBenchmarkGenerationCost += totalTestSuiteGenerationCost
+		totalBenchmarkEvaluationCost += totalTestSuiteEvaluationCost
 	}
 
-	avgBenchmarkRating := totalBenchmarkRating / float64(numTestCases)
-	fmt.Println(strings.Repeat("-", 60))
+	avgBenchmarkRating := domain.Mean(benchmark.Ratings())
+	usage := benchmark.Usage()
+	fmt.Println(strings.Repeat("-", 122))
 	fmt.Printf("Benchmark Summary:\n")
+	if benchmark.SourceRunID != "" {
+		fmt.Printf("Rescored:       outputs of run %s\n", benchmark.SourceRunID)
+	}
+	if benchmark.Status != domain.RunStatusCompleted {
+		fmt.Printf("Status:         %s (%s)\n", benchmark.Status, benchmark.StopReason)
+	}
 	fmt.Printf("Total Duration: %-15s\n", totalBenchmarkDuration.Round(time.Millisecond))
-	fmt.Printf("Total Cost:     $%-15.6f\n", totalBenchmarkCost)
+	fmt.Printf("Generation:     $%-15.6f\n", totalBenchmarkGenerationCost)
+	fmt.Printf("Evaluation:     $%-15.6f\n", totalBenchmarkEvaluationCost)
+	fmt.Printf("Total Cost:     $%-15.6f\n", totalBenchmarkGenerationCost+totalBenchmarkEvaluationCost)
+	if benchmark.CostUnknown() {
+		fmt.Println("Warning:        the cost is incomplete, the price of at least one model is unknown")
+	}
+	fmt.Printf("Total Tokens:   %d (prompt %d, cached %d, completion %d, reasoning %d)\n",
+		usage.TotalTokens(),
+		usage.PromptTokens,
+		usage.CachedTokens,
+		usage.CompletionTokens,
+		usage.ReasoningTokens,
+	)
+	if errors := benchmark.Errors(); errors > 0 {
+		fmt.Printf("Errors:         %d failed repetitions, excluded from the ratings\n", errors)
+	}
 	fmt.Printf("Average Rating: %-10.2f\n", avgBenchmarkRating)
+	fmt.Printf("95%% CI:         %-20s\n", formatConfidenceInterval(benchmark.RatingConfidenceInterval()))
 	fmt.Println()
+	return nil
+}
+
+func (s *StdoutReportGenerator) GenerateComparisonReport(comparison *domain.Comparison) error {
+	fmt.Printf("\nComparison: %s (baseline %s vs. candidate %s)\n", comparison.Benchmark, comparison.BaselineRunID, comparison.CandidateRunID)
+	fmt.Printf("%-20s %-5s %-10s %-10s %-10s %-20s %-12s %-12s\n", "TestSuite", "N", "Baseline", "Candidate", "Diff", "95% CI", "p(bootstr.)", "p(Wilcoxon)")
+	fmt.Println(strings.Repeat("-", 110))
+
+	for _, result := range append(append([]domain.PairedComparison{}, comparison.TestSuites...), comparison.Overall) {
+		fmt.Printf("%-20s %-5d %-10.2f %-10.2f %-10.2f %-20s %-12.4f %-12.4f%s\n",
+			result.Name,
+			result.N,
+			result.BaselineMean,
+			result.CandidateMean,
+			result.MeanDifference.Mean,
+			formatConfidenceInterval(result.MeanDifference),
+			result.BootstrapPValue,
+			result.WilcoxonPValue,
+			significanceMarker(result),
+		)
+	}
+	fmt.Println()
+	return nil
+}
+
+func formatTokens(usage domain.TokenUsage) string {
+	return fmt.Sprintf("%d/%d", usage.PromptTokens, usage.CompletionTokens)
+}
+
+func formatConfidenceInterval(ci domain.ConfidenceInterval) string {
+	return fmt.Sprintf("[%.2f, %.2f]", ci.Lower, ci.Upper)
+}
+
+func significanceMarker(comparison domain.PairedComparison) string {
+	if comparison.IsSignificant(1 - domain.DefaultConfidenceLevel) {
+		return " *"
+	}
+	return ""
+}
+
+// PrintCostEstimate prints the projected cost of a benchmark run.
+func PrintCostEstimate(estimate *domain.BenchmarkEstimate) {
+	fmt.Printf("\nCost Estimate: %s (eval model %s)\n", estimate.Name, estimate.EvalModel)
+	fmt.Printf("%-20s %-15s %-10s %-8s %-15s %-15s %-15s %-15s\n", "TestSuite", "Model", "Cases", "Calls", "Tokens In/Out", "Gen Cost", "Eval Cost", "Budget")
+	fmt.Println(strings.Repeat("-", 120))
+
+	for _, suite := range estimate.TestSuites {
+		fmt.Printf("%-20s %-15s %-10d %-8d %-15s $%-14.6f $%-14.6f %-15s\n",
+			suite.Name,
+			suite.Model,
+			suite.TestCases,
+			suite.Estimate.Calls,
+			fmt.Sprintf("%d/%d", suite.Estimate.PromptTokens, suite.Estimate.CompletionTokens),
+			suite.Estimate.GenerationCost,
+			suite.Estimate.EvaluationCost,
+			formatBudget(suite.Estimate.TotalCost(), suite.MaxCost),
+		)
+	}
+
+	fmt.Println(strings.Repeat("-", 120))
+	fmt.Printf("Estimated Cost: $%.6f (generation $%.6f, evaluation $%.6f)\n", estimate.Total.TotalCost(), estimate.Total.GenerationCost, estimate.Total.EvaluationCost)
+	if estimate.MaxCost > 0 {
+		fmt.Printf("Budget:         %s\n", formatBudget(estimate.Total.TotalCost(), estimate.MaxCost))
+	}
+	if estimate.Total.UnknownCost {
+		fmt.Println("Warning: the price of at least one model is unknown, the estimate is incomplete.")
+		if hasBudget(estimate) {
+			fmt.Println("Warning: calls to models with an unknown price count as $0, the budget does not limit them.")
+		}
+	}
+	fmt.Println("Token counts are heuristic (about 4 characters per token, no model tokenizer), the actual cost may differ.")
+	fmt.Println()
+}
+
+func hasBudget(estimate *domain.BenchmarkEstimate) bool {
+	if estimate.MaxCost > 0 {
+		return true
+	}
+	for _, suite := range estimate.TestSuites {
+		if suite.MaxCost > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func formatBudget(cost, maxCost float64) string {
+	if maxCost <= 0 {
+		return "-"
+	}
+	if cost > maxCost {
+		return fmt.Sprintf("$%.2f EXCEEDED", maxCost)
+	}
+	return fmt.Sprintf("$%.2f", maxCost)
 }
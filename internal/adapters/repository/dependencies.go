@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// sourceExtensions are the extensions of the source files that make a directory a module.
+var sourceExtensions = []string{
+	".go", ".java", ".kt", ".scala", ".cs", ".py", ".js", ".jsx", ".mjs", ".ts", ".tsx",
+	".rb", ".rs", ".php", ".swift", ".c", ".h", ".cpp", ".hpp",
+}
+
+// packageExtensions are the languages that import packages or namespaces instead of paths.
+var packageExtensions = []string{".java", ".kt", ".scala", ".cs"}
+
+var (
+	goModulePattern  = regexp.MustCompile(`(?m)^module\s+(\S+)`)
+	packagePattern   = regexp.MustCompile(`(?m)^\s*(?:package|namespace)\s+([\w.]+)`)
+	jvmImportPattern = regexp.MustCompile(`(?m)^\s*(?:import|using)\s+(?:static\s+)?([\w.]+)`)
+	pyImportPattern  = regexp.MustCompile(`(?m)^\s*(?:from\s+(\.*[\w.]*)\s+import|import\s+([\w.]+))`)
+	jsImportPattern  = regexp.MustCompile(`(?:from\s+|import\s+|require\(\s*)['"](\.[^'"]*)['"]`)
+)
+
+// Module is a directory with source files.
+type Module struct {
+	Path  string
+	Files int
+}
+
+// Dependency is an import of a module by another one.
+type Dependency struct {
+	From string
+	To   string
+}
+
+// IsSource reports whether the file is a source file.
+func IsSource(file string) bool {
+	return slices.Contains(sourceExtensions, strings.ToLower(path.Ext(file)))
+}
+
+// Modules returns the directories with source files in lexical order.
+func (r *Repository) Modules() ([]Module, error) {
+	files, err := r.Files(".")
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, file := range files {
+		if IsSource(file) {
+			counts[path.Dir(file)]++
+		}
+	}
+	var modules []Module
+	for dir, count := range counts {
+		modules = append(modules, Module{Path: dir, Files: count})
+	}
+	slices.SortFunc(modules, func(a, b Module) int { return strings.Compare(a.Path, b.Path) })
+	return modules, nil
+}
+
+// Dependencies returns the imports between the modules, found in the import statements of Go, Java,
+// Kotlin, Scala, C#, Python and JavaScript/TypeScript files. Imports of external libraries and of
+// other languages are not resolved.
+func (r *Repository) Dependencies() ([]Dependency, error) {
+	modules, err := r.Modules()
+	if err != nil {
+		return nil, err
+	}
+	files, err := r.Files(".")
+	if err != nil {
+		return nil, err
+	}
+
+	isModule := map[string]bool{}
+	for _, module := range modules {
+		isModule[module.Path] = true
+	}
+	sources := map[string]string{}
+	goModules := map[string]string{}
+	packages := map[string]string{}
+	for _, file := range files {
+		if !IsSource(file) && path.Base(file) != "go.mod" {
+			continue
+		}
+		source, err := r.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		sources[file] = source
+		if path.Base(file) == "go.mod" {
+			if match := goModulePattern.FindStringSubmatch(source); match != nil {
+				goModules[match[1]] = path.Dir(file)
+			}
+		} else if slices.Contains(packageExtensions, strings.ToLower(path.Ext(file))) {
+			if match := packagePattern.FindStringSubmatch(source); match != nil {
+				packages[match[1]] = path.Dir(file)
+			}
+		}
+	}
+
+	seen := map[Dependency]bool{}
+	var dependencies []Dependency
+	for _, file := range files {
+		source, ok := sources[file]
+		if !ok || path.Base(file) == "go.mod" {
+			continue
+		}
+		from := path.Dir(file)
+		for _, to := range imports(file, source, goModules, packages, isModule) {
+			dependency := Dependency{From: from, To: to}
+			if to == from || seen[dependency] {
+				continue
+			}
+			seen[dependency] = true
+			dependencies = append(dependencies, dependency)
+		}
+	}
+	slices.SortFunc(dependencies, func(a, b Dependency) int {
+		return strings.Compare(a.From+"\x00"+a.To, b.From+"\x00"+b.To)
+	})
+	return dependencies, nil
+}
+
+// imports returns the modules a source file imports.
+func imports(file, source string, goModules, packages map[string]string, isModule map[string]bool) []string {
+	dir := path.Dir(file)
+	var modules []string
+	addDir := func(dir string) {
+		if isModule[dir] {
+			modules = append(modules, dir)
+		}
+	}
+
+	extension := strings.ToLower(path.Ext(file))
+	switch {
+	case extension == ".go":
+		parsed, err := parser.ParseFile(token.NewFileSet(), file, source, parser.ImportsOnly)
+		if err != nil {
+			return nil
+		}
+		for _, spec := range parsed.Imports {
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+			for modulePath, moduleDir := range goModules {
+				if importPath == modulePath || strings.HasPrefix(importPath, modulePath+"/") {
+					addDir(path.Join(moduleDir, strings.TrimPrefix(importPath, modulePath)))
+				}
+			}
+		}
+	case slices.Contains(packageExtensions, extension):
+		for _, match := range jvmImportPattern.FindAllStringSubmatch(source, -1) {
+			// The import names a class or a static member, the longest known prefix is the package
+			name := match[1]
+			for name != "" {
+				if packageDir, ok := packages[name]; ok {
+					modules = append(modules, packageDir)
+					break
+				}
+				name = name[:max(0, strings.LastIndex(name, "."))]
+			}
+		}
+	case extension == ".py":
+		for _, match := range pyImportPattern.FindAllStringSubmatch(source, -1) {
+			name := match[1] + match[2]
+			// Relative imports start in the package of the file, absolute ones at the root or in src
+			bases := []string{".", "src"}
+			if dots := len(name) - len(strings.TrimLeft(name, ".")); dots > 0 {
+				base := dir
+				for range dots - 1 {
+					base = path.Dir(base)
+				}
+				bases = []string{base}
+				name = name[dots:]
+				if name == "" {
+					addDir(base)
+					continue
+				}
+			}
+			parts := strings.Split(name, ".")
+			for _, base := range bases {
+				// The import names a module file or a package, the longest existing directory is the module
+				for i := len(parts); i > 0; i-- {
+					if candidate := path.Join(base, path.Join(parts[:i]...)); isModule[candidate] {
+						addDir(candidate)
+						break
+					}
+				}
+			}
+		}
+	case slices.Contains([]string{".js", ".jsx", ".mjs", ".ts", ".tsx"}, extension):
+		for _, match := range jsImportPattern.FindAllStringSubmatch(source, -1) {
+			target := path.Join(dir, match[1])
+			if isModule[target] {
+				addDir(target)
+			} else {
+				// An import of a file
+				addDir(path.Dir(target))
+			}
+		}
+	}
+	return modules
+}
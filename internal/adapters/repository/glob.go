@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Globs are compiled glob patterns on the slash separated paths of a repository. * matches within
+// a path segment, ** across segments. A pattern without a slash matches a name in any directory,
+// e.g. *.go or node_modules. A pattern matches a file if it matches its path or one of its
+// directories, so internal/legacy matches every file below it.
+type Globs []*regexp.Regexp
+
+// CompileGlobs compiles the patterns.
+func CompileGlobs(patterns []string) (Globs, error) {
+	globs := make(Globs, len(patterns))
+	for i, pattern := range patterns {
+		glob, err := compileGlob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+		globs[i] = glob
+	}
+	return globs, nil
+}
+
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "./"), "/")
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// Match returns the index of the first pattern that matches the file or one of its directories,
+// -1 if none matches.
+func (g Globs) Match(file string) int {
+	for i, glob := range g {
+		for candidate := file; candidate != "." && candidate != "/"; candidate = path.Dir(candidate) {
+			if glob.MatchString(candidate) {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		// ** matches across directories, * and ? within one
+		{pattern: "internal/**/*.go", match: []string{"internal/a.go", "internal/core/a.go", "internal/core/services/a.go"}, noMatch: []string{"cmd/a.go", "internal/core/a.md"}},
+		{pattern: "**/testdata/*", match: []string{"testdata/a.txt", "pkg/testdata/a.txt"}, noMatch: []string{"testdata/sub/a.txt"}},
+		{pattern: "docs/**", match: []string{"docs/a.md", "docs/sub/a.md"}, noMatch: []string{"docs", "src/docs/a.md"}},
+		{pattern: "src/*.go", match: []string{"src/main.go"}, noMatch: []string{"src/sub/main.go", "main.go"}},
+		{pattern: "src/?.go", match: []string{"src/a.go"}, noMatch: []string{"src/ab.go", "src//.go"}},
+		// A pattern without a slash matches a name in any directory
+		{pattern: "*.go", match: []string{"main.go", "src/main.go", "a/b/c.go"}, noMatch: []string{"main.go.bak", "src/main.md"}},
+		{pattern: "go.mod", match: []string{"go.mod", "tools/go.mod"}, noMatch: []string{"go.mod.orig", "gosmod"}},
+		// A leading ./ and a trailing slash are ignored
+		{pattern: "./src/main.go", match: []string{"src/main.go"}, noMatch: []string{"main.go"}},
+		{pattern: "vendor/", match: []string{"vendor", "a/vendor"}, noMatch: []string{"vendors"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			glob, err := compileGlob(tt.pattern)
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			for _, path := range tt.match {
+				if !glob.MatchString(path) {
+					t.Errorf("%q does not match %q", tt.pattern, path)
+				}
+			}
+			for _, path := range tt.noMatch {
+				if glob.MatchString(path) {
+					t.Errorf("%q matches %q", tt.pattern, path)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileGlobsInvalid(t *testing.T) {
+	for _, pattern := range []string{"", "./", "/"} {
+		if _, err := CompileGlobs([]string{"*.go", pattern}); err == nil || !strings.Contains(err.Error(), "empty pattern") {
+			t.Errorf("compile %q: error = %v, want empty pattern", pattern, err)
+		}
+	}
+}
+
+func TestGlobsMatch(t *testing.T) {
+	globs, err := CompileGlobs([]string{"go.mod", "internal/legacy", "vendor", "**/*.go"})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	tests := []struct {
+		file string
+		want int
+	}{
+		{file: "go.mod", want: 0},
+		// A directory match covers every file below it
+		{file: "internal/legacy/old.go", want: 1},
+		{file: "internal/legacy/sub/old.md", want: 1},
+		{file: "internal/legacy.md", want: -1},
+		{file: "vendor/example.com/lib/lib.go", want: 2},
+		{file: "tools/vendor/README.md", want: 2},
+		// The first matching pattern wins
+		{file: "internal/core/service.go", want: 3},
+		{file: "README.md", want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if got := globs.Match(tt.file); got != tt.want {
+				t.Errorf("match = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PackOptions select the files of a repository that are packed into a prompt.
+type PackOptions struct {
+	// Include are the globs of the files whose content is packed, all text files if empty
+	Include []string
+	// Exclude are the globs of the files that are left out of the file tree and the contents
+	Exclude   []string
+	MaxTokens int
+	// EstimateTokens estimates the tokens of a text for the budget
+	EstimateTokens func(text string) int
+}
+
+// Packed is a repository rendered as text for a prompt.
+type Packed struct {
+	Text string
+	// Files are the files whose content is packed, Omitted the included files that did not fit
+	Files   []string
+	Omitted []string
+	Tokens  int
+}
+
+// Pack renders the repository for a prompt: the file tree and the content of the included text
+// files, within the token budget. Files are packed in the order of the include patterns, shallow
+// files first. A file that does not fit is skipped and named at the end, so the model knows of it.
+// At most half of the budget is spent on the file tree. The tags around the tree, the contents and
+// the omitted files count against the budget, too.
+func (r *Repository) Pack(options PackOptions) (Packed, error) {
+	include, err := CompileGlobs(options.Include)
+	if err != nil {
+		return Packed{}, err
+	}
+	exclude, err := CompileGlobs(options.Exclude)
+	if err != nil {
+		return Packed{}, err
+	}
+	files, err := r.Files(".")
+	if err != nil {
+		return Packed{}, err
+	}
+	files = slices.DeleteFunc(files, func(file string) bool { return exclude.Match(file) >= 0 })
+
+	estimate := options.EstimateTokens
+	wrapperTokens := estimate(repositoryHeader + treeFooter + repositoryFooter)
+	tree, treeTokens := fileList(files, (options.MaxTokens-wrapperTokens)/2, estimate)
+	packed := Packed{Tokens: wrapperTokens + treeTokens}
+	var contents strings.Builder
+
+	type candidate struct {
+		file     string
+		priority int
+	}
+	var candidates []candidate
+	for _, file := range files {
+		priority := 0
+		if len(include) > 0 {
+			if priority = include.Match(file); priority < 0 {
+				continue
+			}
+		}
+		candidates = append(candidates, candidate{file: file, priority: priority})
+	}
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		if a.priority != b.priority {
+			return a.priority - b.priority
+		}
+		return strings.Count(a.file, "/") - strings.Count(b.file, "/")
+	})
+
+	// Room is kept for the omitted tags and at least the count of the omitted files
+	omittedTokens := estimate(omittedHeader + omittedFooter)
+	contentBudget := options.MaxTokens - omittedTokens - estimate(moreFiles(len(candidates)))
+	for _, candidate := range candidates {
+		content, err := r.ReadFile(candidate.file)
+		if err != nil {
+			// Binary files are only listed in the tree
+			continue
+		}
+		block := fmt.Sprintf("<file path=%q>\n%s\n</file>\n", candidate.file, strings.TrimRight(content, "\n"))
+		tokens := estimate(block)
+		if packed.Tokens+tokens > contentBudget {
+			packed.Omitted = append(packed.Omitted, candidate.file)
+			continue
+		}
+		contents.WriteString(block)
+		packed.Files = append(packed.Files, candidate.file)
+		packed.Tokens += tokens
+	}
+
+	var text strings.Builder
+	text.WriteString(repositoryHeader)
+	text.WriteString(tree)
+	text.WriteString(treeFooter)
+	text.WriteString(contents.String())
+	if len(packed.Omitted) > 0 {
+		omitted, tokens := fileList(packed.Omitted, options.MaxTokens-packed.Tokens-omittedTokens, estimate)
+		text.WriteString(omittedHeader)
+		text.WriteString(omitted)
+		text.WriteString(omittedFooter)
+		packed.Tokens += omittedTokens + tokens
+	}
+	text.WriteString(repositoryFooter)
+	packed.Text = text.String()
+	return packed, nil
+}
+
+const (
+	repositoryHeader = "<repository>\n<file_tree>\n"
+	treeFooter       = "</file_tree>\n"
+	repositoryFooter = "</repository>"
+	omittedHeader    = "<omitted reason=\"token budget\">\n"
+	omittedFooter    = "</omitted>\n"
+)
+
+func moreFiles(count int) string {
+	return fmt.Sprintf("[%d more files]\n", count)
+}
+
+// fileList lists the files one per line within the token budget, the files that do not fit are
+// counted in a last line. It returns the list and its tokens.
+func fileList(files []string, maxTokens int, estimateTokens func(string) int) (string, int) {
+	lines := make([]string, len(files))
+	lineTokens := make([]int, len(files))
+	total := 0
+	for i, file := range files {
+		lines[i] = file + "\n"
+		lineTokens[i] = estimateTokens(lines[i])
+		total += lineTokens[i]
+	}
+	if total <= maxTokens {
+		return strings.Join(lines, ""), total
+	}
+
+	// The count line is reserved for, it is never longer than for all files
+	maxTokens -= estimateTokens(moreFiles(len(files)))
+	var list strings.Builder
+	tokens := 0
+	for i, line := range lines {
+		if tokens+lineTokens[i] > maxTokens {
+			more := moreFiles(len(files) - i)
+			list.WriteString(more)
+			return list.String(), tokens + estimateTokens(more)
+		}
+		list.WriteString(line)
+		tokens += lineTokens[i]
+	}
+	return list.String(), tokens
+}
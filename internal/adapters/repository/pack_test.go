@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPackCountsOverhead(t *testing.T) {
+	root := t.TempDir()
+	for i := range 20 {
+		path := filepath.Join(root, "src", fmt.Sprintf("file%02d.go", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", 100)+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repository, err := Open(root)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		maxTokens   int
+		wantFiles   int
+		wantOmitted bool
+		wantMore    bool
+	}{
+		{name: "everything fits", maxTokens: 10000, wantFiles: 20},
+		{name: "omitted files", maxTokens: 3000, wantFiles: 19, wantOmitted: true},
+		{name: "omitted files counted", maxTokens: 900, wantFiles: 3, wantOmitted: true, wantMore: true},
+		{name: "tree cut", maxTokens: 300, wantFiles: 0, wantOmitted: true, wantMore: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// One token per byte, so the packed text is counted exactly
+			packed, err := repository.Pack(PackOptions{
+				MaxTokens:      tt.maxTokens,
+				EstimateTokens: func(text string) int { return len(text) },
+			})
+			if err != nil {
+				t.Fatalf("pack: %v", err)
+			}
+			if packed.Tokens != len(packed.Text) {
+				t.Errorf("tokens = %d, want the length of the text %d", packed.Tokens, len(packed.Text))
+			}
+			if packed.Tokens > tt.maxTokens {
+				t.Errorf("tokens = %d, exceed the budget of %d", packed.Tokens, tt.maxTokens)
+			}
+			if len(packed.Files) != tt.wantFiles {
+				t.Errorf("files = %d, want %d", len(packed.Files), tt.wantFiles)
+			}
+			if got := strings.Contains(packed.Text, "<omitted"); got != tt.wantOmitted {
+				t.Errorf("omitted in text = %v, want %v", got, tt.wantOmitted)
+			}
+			if got := strings.Contains(packed.Text, "more files]\n</omitted>"); got != tt.wantMore {
+				t.Errorf("omitted files counted = %v, want %v:\n%s", got, tt.wantMore, packed.Text)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// skippedDirs are not part of the source tree: version control, dependency, cache and build output
+// directories.
+var skippedDirs = []string{".git", ".hg", ".svn", "node_modules", "vendor", "__pycache__", ".venv", "target", "build", "dist"}
+
+// Repository is a local source tree, e.g. the fixture of a test case. Paths are relative to its
+// root and slash separated, nothing outside of the root can be read, not even through symlinks.
+type Repository struct {
+	root string
+}
+
+// Open opens the source tree in dir.
+func Open(dir string) (*Repository, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening repository: %w", err)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("error opening repository: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("repository %s is not a directory", dir)
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("error opening repository: %w", err)
+	}
+	return &Repository{root: root}, nil
+}
+
+// resolve returns the absolute path of a path in the repository. Absolute paths and .. are taken
+// relative to the root.
+func (r *Repository) resolve(path string) (string, error) {
+	fullPath := filepath.Join(r.root, filepath.Clean("/"+filepath.FromSlash(path)))
+	realPath, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("%s does not exist", path)
+	}
+	if realPath != r.root && !strings.HasPrefix(realPath, r.root+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the repository", path)
+	}
+	return realPath, nil
+}
+
+// Files returns the files under dir in lexical order. Version control and dependency directories
+// and symlinks are skipped.
+func (r *Repository) Files(dir string) ([]string, error) {
+	fullDir, err := r.resolve(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err = filepath.WalkDir(fullDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != fullDir && slices.Contains(skippedDirs, entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(r.root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing files: %w", err)
+	}
+	return files, nil
+}
+
+// ReadFile reads a text file of the repository.
+func (r *Repository) ReadFile(path string) (string, error) {
+	fullPath, err := r.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if !IsText(data) {
+		return "", fmt.Errorf("%s is not a text file", path)
+	}
+	return string(data), nil
+}
+
+// IsText reports whether data is UTF-8 text without NUL bytes.
+func IsText(data []byte) bool {
+	return utf8.Valid(data) && !bytes.Contains(data, []byte{0})
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestRepository creates a repository with a file and a directory, next to a secret outside of it.
+func newTestRepository(t *testing.T) (*Repository, string) {
+	t.Helper()
+	dir := t.TempDir()
+	root := filepath.Join(dir, "repo")
+	for path, content := range map[string]string{
+		"secret.txt":         "secret",
+		"repo/go.mod":        "module example.com/repo\n",
+		"repo/src/main.go":   "package main\n",
+		"repo/src/README.md": "# src\n",
+	} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"secret-link.txt": filepath.Join(dir, "secret.txt"),
+		"outside":         dir,
+		"main-link.go":    filepath.Join(root, "src", "main.go"),
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repository, err := Open(root)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return repository, root
+}
+
+func TestRepositoryResolve(t *testing.T) {
+	repository, root := newTestRepository(t)
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr string
+	}{
+		{path: "go.mod", want: "go.mod"},
+		{path: "./src/../go.mod", want: "go.mod"},
+		{path: "", want: "."},
+		{path: "/src/main.go", want: "src/main.go"},
+		// .. and absolute paths stay in the root
+		{path: "../secret.txt", wantErr: "does not exist"},
+		{path: "src/../../secret.txt", wantErr: "does not exist"},
+		{path: filepath.Join(filepath.Dir(root), "secret.txt"), wantErr: "does not exist"},
+		// Symlinks are followed only within the root
+		{path: "main-link.go", want: "src/main.go"},
+		{path: "secret-link.txt", wantErr: "outside of the repository"},
+		{path: "outside/secret.txt", wantErr: "outside of the repository"},
+		{path: "missing.go", wantErr: "does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := repository.resolve(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolve = %q, %v, want error %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			if want := filepath.Join(root, filepath.FromSlash(tt.want)); got != want {
+				t.Errorf("resolve = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRepositoryFilesSkipsSymlinks(t *testing.T) {
+	repository, _ := newTestRepository(t)
+
+	files, err := repository.Files("")
+	if err != nil {
+		t.Fatalf("files: %v", err)
+	}
+	if want := []string{"go.mod", "src/README.md", "src/main.go"}; !slices.Equal(files, want) {
+		t.Errorf("files = %q, want %q", files, want)
+	}
+}
@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+const (
+	// maxToolOutput limits the result of a tool call, so a single call cannot fill the context
+	maxToolOutput  = 32 * 1024
+	maxListedFiles = 500
+	maxMatches     = 100
+)
+
+// tool is a built-in read-only tool on a repository.
+type tool struct {
+	definition domain.Tool
+	run        func(r *Repository, arguments toolArguments) (string, error)
+}
+
+type toolArguments struct {
+	Path    string `json:"path"`
+	Pattern string `json:"pattern"`
+	Module  string `json:"module"`
+}
+
+func stringParameter(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+func parameters(properties map[string]any, required ...string) map[string]any {
+	return map[string]any{"type": "object", "properties": properties, "required": append([]string{}, required...)}
+}
+
+var tools = []tool{
+	{
+		definition: domain.Tool{
+			Name:        "list_files",
+			Description: "Lists the files of the repository under a directory, recursively.",
+			Parameters: parameters(map[string]any{
+				"path":    stringParameter("Directory relative to the repository root, the root if empty"),
+				"pattern": stringParameter("Glob the file names have to match, e.g. *.go"),
+			}),
+		},
+		run: listFiles,
+	},
+	{
+		definition: domain.Tool{
+			Name:        "read_file",
+			Description: "Reads a text file of the repository.",
+			Parameters: parameters(map[string]any{
+				"path": stringParameter("File path relative to the repository root"),
+			}, "path"),
+		},
+		run: readFile,
+	},
+	{
+		definition: domain.Tool{
+			Name:        "search",
+			Description: "Searches the text files of the repository for a regular expression and returns the matching lines.",
+			Parameters: parameters(map[string]any{
+				"pattern": stringParameter("Regular expression in Go syntax"),
+				"path":    stringParameter("Directory relative to the repository root, the root if empty"),
+			}, "pattern"),
+		},
+		run: search,
+	},
+	{
+		definition: domain.Tool{
+			Name:        "list_modules",
+			Description: "Lists the modules of the repository, the directories with source files, with their number of source files.",
+			Parameters:  parameters(map[string]any{}),
+		},
+		run: listModules,
+	},
+	{
+		definition: domain.Tool{
+			Name:        "dependencies",
+			Description: "Queries the dependency graph of the modules, derived from the import statements. Returns the dependencies and dependents of a module, or every dependency.",
+			Parameters: parameters(map[string]any{
+				"module": stringParameter("Module path as returned by list_modules, the whole graph if empty"),
+			}),
+		},
+		run: dependencies,
+	},
+}
+
+// ToolNames returns the names of the built-in tools.
+func ToolNames() []string {
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.definition.Name
+	}
+	return names
+}
+
+// Toolbox runs the built-in read-only tools on a repository.
+type Toolbox struct {
+	repository *Repository
+	tools      []tool
+}
+
+// NewToolbox returns the allowed tools on the repository, all tools if allow is empty.
+func NewToolbox(repository *Repository, allow []string) (*Toolbox, error) {
+	toolbox := &Toolbox{repository: repository}
+	for _, name := range allow {
+		if !slices.Contains(ToolNames(), name) {
+			return nil, fmt.Errorf("unknown tool %q, known tools are %s", name, strings.Join(ToolNames(), ", "))
+		}
+	}
+	for _, tool := range tools {
+		if len(allow) == 0 || slices.Contains(allow, tool.definition.Name) {
+			toolbox.tools = append(toolbox.tools, tool)
+		}
+	}
+	return toolbox, nil
+}
+
+// Definitions returns the definitions of the tools for the provider.
+func (t *Toolbox) Definitions() []domain.Tool {
+	definitions := make([]domain.Tool, len(t.tools))
+	for i, tool := range t.tools {
+		definitions[i] = tool.definition
+	}
+	return definitions
+}
+
+// Execute runs a tool call. The error of a failed call is meant for the model, e.g. a missing file.
+func (t *Toolbox) Execute(call domain.ToolCall) (string, error) {
+	index := slices.IndexFunc(t.tools, func(tool tool) bool { return tool.definition.Name == call.Name })
+	if index < 0 {
+		return "", fmt.Errorf("unknown tool %q", call.Name)
+	}
+	var arguments toolArguments
+	if strings.TrimSpace(call.Arguments) != "" {
+		if err := json.Unmarshal([]byte(call.Arguments), &arguments); err != nil {
+			return "", fmt.Errorf("invalid arguments: %v", err)
+		}
+	}
+
+	output, err := t.tools[index].run(t.repository, arguments)
+	if err != nil {
+		return "", err
+	}
+	if len(output) > maxToolOutput {
+		output = strings.ToValidUTF8(output[:maxToolOutput], "") + fmt.Sprintf("\n[truncated, %d of %d bytes]", maxToolOutput, len(output))
+	}
+	if output == "" {
+		output = "(no results)"
+	}
+	return output, nil
+}
+
+func listFiles(r *Repository, arguments toolArguments) (string, error) {
+	files, err := r.Files(defaultPath(arguments.Path))
+	if err != nil {
+		return "", err
+	}
+	var listed []string
+	for _, file := range files {
+		if arguments.Pattern != "" {
+			matched, err := path.Match(arguments.Pattern, path.Base(file))
+			if err != nil {
+				return "", fmt.Errorf("invalid pattern: %v", err)
+			}
+			if !matched {
+				continue
+			}
+		}
+		listed = append(listed, file)
+	}
+	if len(listed) > maxListedFiles {
+		return strings.Join(listed[:maxListedFiles], "\n") + fmt.Sprintf("\n[%d more files]", len(listed)-maxListedFiles), nil
+	}
+	return strings.Join(listed, "\n"), nil
+}
+
+func readFile(r *Repository, arguments toolArguments) (string, error) {
+	if arguments.Path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	return r.ReadFile(arguments.Path)
+}
+
+func search(r *Repository, arguments toolArguments) (string, error) {
+	pattern, err := regexp.Compile(arguments.Pattern)
+	if err != nil || arguments.Pattern == "" {
+		return "", fmt.Errorf("invalid pattern %q", arguments.Pattern)
+	}
+	files, err := r.Files(defaultPath(arguments.Path))
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, file := range files {
+		content, err := r.ReadFile(file)
+		if err != nil {
+			// Binary files are not searched
+			continue
+		}
+		for i, line := range strings.Split(content, "\n") {
+			if !pattern.MatchString(line) {
+				continue
+			}
+			if len(matches) == maxMatches {
+				return strings.Join(matches, "\n") + "\n[more matches, narrow the search]", nil
+			}
+			matches = append(matches, fmt.Sprintf("%s:%d: %s", file, i+1, strings.TrimSpace(line)))
+		}
+	}
+	return strings.Join(matches, "\n"), nil
+}
+
+func listModules(r *Repository, _ toolArguments) (string, error) {
+	modules, err := r.Modules()
+	if err != nil {
+		return "", err
+	}
+	lines := make([]string, len(modules))
+	for i, module := range modules {
+		lines[i] = fmt.Sprintf("%s (%d files)", module.Path, module.Files)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func dependencies(r *Repository, arguments toolArguments) (string, error) {
+	graph, err := r.Dependencies()
+	if err != nil {
+		return "", err
+	}
+	module := path.Clean(strings.Trim(arguments.Module, "/"))
+	if arguments.Module == "" {
+		lines := make([]string, len(graph))
+		for i, dependency := range graph {
+			lines[i] = dependency.From + " -> " + dependency.To
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+
+	modules, err := r.Modules()
+	if err != nil {
+		return "", err
+	}
+	if !slices.ContainsFunc(modules, func(m Module) bool { return m.Path == module }) {
+		return "", fmt.Errorf("unknown module %q, see list_modules", arguments.Module)
+	}
+	var uses, usedBy []string
+	for _, dependency := range graph {
+		if dependency.From == module {
+			uses = append(uses, dependency.To)
+		}
+		if dependency.To == module {
+			usedBy = append(usedBy, dependency.From)
+		}
+	}
+	return fmt.Sprintf("%s depends on:\n%s\n\n%s is used by:\n%s", module, listOrNone(uses), module, listOrNone(usedBy)), nil
+}
+
+func defaultPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
+
+func listOrNone(items []string) string {
+	if len(items) == 0 {
+		return "(none)"
+	}
+	return strings.Join(items, "\n")
+}
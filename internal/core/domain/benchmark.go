@@ -1,10 +1,40 @@
 package domain
 
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	RunStatusCompleted      = "completed"
+	RunStatusBudgetExceeded = "budget_exceeded"
+	RunStatusInterrupted    = "interrupted"
+	RunStatusTimedOut       = "timed_out"
+	// RunStatusFailed is a run that stopped at a failed test case, with --fail-fast or on an error
+	// that is not a test case failure
+	RunStatusFailed = "failed"
+)
+
 type Benchmark struct {
-	Name         string
-	EvalProvider string
-	EvalModel    string
-	TestSuites   []*TestSuite
+	Name  string `json:"name"`
+	RunID string `json:"run_id"`
+	// SourceRunID is set for a rescored run, it links to the run whose outputs were scored again
+	SourceRunID string `json:"source_run_id,omitempty"`
+	// BenchmarkDir is the directory of the benchmark the run was made with, rescore loads it from there
+	BenchmarkDir string       `json:"benchmark_dir,omitempty"`
+	StartedAt    time.Time    `json:"started_at"`
+	Status       string       `json:"status"`
+	StopReason   string       `json:"stop_reason,omitempty"`
+	EvalProvider string       `json:"eval_provider"`
+	EvalModel    string       `json:"eval_model"`
+	TestSuites   []*TestSuite `json:"test_suites"`
+}
+
+// NewRunID returns the ID of a run started at startedAt. The random suffix keeps runs that are
+// started in the same second apart, e.g. in CI.
+func NewRunID(startedAt time.Time) string {
+	return fmt.Sprintf("%s-%04x", startedAt.Format("20060102-150405"), rand.Intn(0x10000))
 }
 
 type MeasurementConfig struct {
@@ -13,22 +43,179 @@ type MeasurementConfig struct {
 	Weight float64
 }
 
+// MetricConfig selects a metric of a test suite and its weight in the rating of a test case.
 type MetricConfig struct {
-	Name        string
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+	// Criteria replaces the default evaluation criteria of the geval metric
+	Criteria    string `json:"criteria,omitempty"`
 	Mesurements []MeasurementConfig
 }
 
+// DefaultMetricConfigs are used for test suites without a metric configuration.
+var DefaultMetricConfigs = []MetricConfig{
+	{Name: "geval", Weight: 1},
+	{Name: "relevance", Weight: 1},
+}
+
+// KnownMetrics are the metrics a test suite can be configured with.
+var KnownMetrics = []string{"geval", "relevance", "tool_trajectory"}
+
+// ProviderConfig holds the credentials and the endpoint of a provider, an empty BaseURL uses the
+// default endpoint.
+type ProviderConfig struct {
+	APIKey  string
+	BaseURL string
+}
+
 type BenchmarkConfig struct {
-	Name             string
-	Description      string
-	Version          string
-	EvalApiKey       string
-	EvalModel        string
-	EvalProvider     string
-	OpenAIAPIKey     string
+	Name        string
+	Description string
+	Version     string
+	// Dir is the directory of the benchmark
+	Dir string `json:"-"`
+	// EvalApiKey replaces the API key of the eval provider for the judge calls, if set
+	EvalApiKey   string
+	EvalModel    string
+	EvalProvider string
+	// Providers holds the credentials of the providers, keyed by provider
+	Providers   map[string]ProviderConfig `json:"-"`
+	ResultsPath string
+	Repetitions int
+	// FailFast aborts the run on the first failed test case instead of recording the failure
+	FailFast bool
+	MaxCost  float64 `json:"max_cost"`
+	// CallTimeoutSeconds limits every single provider call, RunTimeoutSeconds the whole run (0 = no limit)
+	CallTimeoutSeconds int `json:"call_timeout_seconds"`
+	RunTimeoutSeconds  int `json:"run_timeout_seconds"`
+	Pricing            *PricingCatalog
+	// CacheMode controls the on-disk response cache in CachePath, it is off by default
+	CacheMode CacheMode `json:"cache"`
+	CachePath string
+	// DiagramsPath caches the diagrams rendered for test suites in image mode
+	DiagramsPath string
+	// ReplayMode records the provider responses as fixtures in FixturesPath or replays them from there
+	ReplayMode   ReplayMode `json:"-"`
+	FixturesPath string
+	// Resilience configures retries, rate limits and the circuit breaker, keyed by provider
+	Resilience       map[string]ResilienceConfig `json:"resilience"`
 	TestSuiteConfigs []TestSuiteConfig
 }
 
+func (c *BenchmarkConfig) CallTimeout() time.Duration {
+	return time.Duration(c.CallTimeoutSeconds) * time.Second
+}
+
+func (c *BenchmarkConfig) RunTimeout() time.Duration {
+	return time.Duration(c.RunTimeoutSeconds) * time.Second
+}
+
 type EvaluationResult struct {
 	Score float64
 }
+
+// Ratings returns the average rating of every test case across all test suites.
+func (b *Benchmark) Ratings() []float64 {
+	var ratings []float64
+	for _, testSuite := range b.TestSuites {
+		ratings = append(ratings, testSuite.Ratings()...)
+	}
+	return ratings
+}
+
+func (b *Benchmark) Usage() TokenUsage {
+	var usage TokenUsage
+	for _, testSuite := range b.TestSuites {
+		usage = usage.Add(testSuite.Usage())
+	}
+	return usage
+}
+
+func (b *Benchmark) GenerationCost() float64 {
+	var cost float64
+	for _, testSuite := range b.TestSuites {
+		cost += testSuite.GenerationCost()
+	}
+	return cost
+}
+
+func (b *Benchmark) EvaluationCost() float64 {
+	var cost float64
+	for _, testSuite := range b.TestSuites {
+		cost += testSuite.EvaluationCost()
+	}
+	return cost
+}
+
+func (b *Benchmark) CostUnknown() bool {
+	for _, testSuite := range b.TestSuites {
+		if testSuite.CostUnknown() {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors counts the failed repetitions of all test cases.
+func (b *Benchmark) Errors() int {
+	errors := 0
+	for _, testSuite := range b.TestSuites {
+		errors += testSuite.Errors()
+	}
+	return errors
+}
+
+func (b *Benchmark) RatingConfidenceInterval() ConfidenceInterval {
+	return BootstrapConfidenceInterval(b.Ratings(), DefaultConfidenceLevel, DefaultBootstrapIterations)
+}
+
+// Comparison pairs the test cases of two benchmark runs by test suite and test case name.
+type Comparison struct {
+	Benchmark      string             `json:"benchmark"`
+	BaselineRunID  string             `json:"baseline_run_id"`
+	CandidateRunID string             `json:"candidate_run_id"`
+	TestSuites     []PairedComparison `json:"test_suites"`
+	Overall        PairedComparison   `json:"overall"`
+}
+
+func NewComparison(baseline, candidate *Benchmark) *Comparison {
+	comparison := &Comparison{
+		Benchmark:      candidate.Name,
+		BaselineRunID:  baseline.RunID,
+		CandidateRunID: candidate.RunID,
+	}
+
+	var allBaseline, allCandidate []float64
+	for _, candidateSuite := range candidate.TestSuites {
+		baselineSuite := baseline.TestSuite(candidateSuite.Name)
+		if baselineSuite == nil {
+			continue
+		}
+
+		var baselineRatings, candidateRatings []float64
+		for _, testCase := range candidateSuite.TestCases {
+			baselineCase := baselineSuite.TestCase(testCase.Name)
+			if baselineCase == nil || len(baselineCase.Ratings()) == 0 || len(testCase.Ratings()) == 0 {
+				continue
+			}
+			baselineRatings = append(baselineRatings, baselineCase.CalculateAverageRating())
+			candidateRatings = append(candidateRatings, testCase.CalculateAverageRating())
+		}
+
+		comparison.TestSuites = append(comparison.TestSuites, ComparePaired(candidateSuite.Name, baselineRatings, candidateRatings))
+		allBaseline = append(allBaseline, baselineRatings...)
+		allCandidate = append(allCandidate, candidateRatings...)
+	}
+	comparison.Overall = ComparePaired("overall", allBaseline, allCandidate)
+
+	return comparison
+}
+
+func (b *Benchmark) TestSuite(name string) *TestSuite {
+	for _, testSuite := range b.TestSuites {
+		if testSuite.Name == name {
+			return testSuite
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package domain
+
+import "fmt"
+
+// CacheMode controls how provider responses are cached on disk.
+type CacheMode string
+
+const (
+	// CacheModeReadWrite serves cached responses and stores new ones
+	CacheModeReadWrite CacheMode = "read-write"
+	// CacheModeReadOnly serves cached responses but never stores new ones
+	CacheModeReadOnly CacheMode = "read-only"
+	// CacheModeRefresh always calls the provider and replaces the cached responses
+	CacheModeRefresh CacheMode = "refresh"
+	// CacheModeOff disables the cache
+	CacheModeOff CacheMode = "off"
+)
+
+func ParseCacheMode(mode string) (CacheMode, error) {
+	switch CacheMode(mode) {
+	case "", CacheModeOff:
+		return CacheModeOff, nil
+	case CacheModeReadWrite, CacheModeReadOnly, CacheModeRefresh:
+		return CacheMode(mode), nil
+	default:
+		return "", fmt.Errorf("unknown cache mode %q, expected read-write, read-only, refresh or off", mode)
+	}
+}
+
+func (m CacheMode) Reads() bool {
+	return m == CacheModeReadWrite || m == CacheModeReadOnly
+}
+
+func (m CacheMode) Writes() bool {
+	return m == CacheModeReadWrite || m == CacheModeRefresh
+}
+
+// ReplayMode controls the recording and replaying of provider responses as fixtures.
+type ReplayMode string
+
+const (
+	// ReplayModeRecord calls the providers and saves every request and response as a fixture
+	ReplayModeRecord ReplayMode = "record"
+	// ReplayModeReplay serves the responses from the fixtures without calling any provider
+	ReplayModeReplay ReplayMode = "replay"
+	// ReplayModeOff calls the providers without recording
+	ReplayModeOff ReplayMode = "off"
+)
+
+func ParseReplayMode(mode string) (ReplayMode, error) {
+	switch ReplayMode(mode) {
+	case "", ReplayModeOff:
+		return ReplayModeOff, nil
+	case ReplayModeRecord, ReplayModeReplay:
+		return ReplayMode(mode), nil
+	default:
+		return "", fmt.Errorf("unknown replay mode %q, expected record, replay or off", mode)
+	}
+}
@@ -0,0 +1,85 @@
+package domain
+
+import "fmt"
+
+// Roles of the messages of a conversation.
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Turn is a message of a scripted conversation. The model answers every user turn that is not
+// followed by a scripted assistant turn, scripted turns replace the answer of the model.
+type Turn struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Expected is the expected answer to a user turn, scored for the final turn and with ScoreTurnsAll
+	Expected string `json:"expected,omitempty"`
+}
+
+// Message is a previous message of the conversation a request continues. An assistant message can
+// call tools, a tool message holds the result of the call ToolCallID.
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// ScoreTurns selects the answers of a conversation that are scored.
+type ScoreTurns string
+
+const (
+	// ScoreTurnsFinal scores the final answer against the expected answer of the final turn or the
+	// expected output of the test case
+	ScoreTurnsFinal ScoreTurns = "final"
+	// ScoreTurnsAll scores every answer to a user turn with an expected answer, the final answer
+	// falls back to the expected output of the test case
+	ScoreTurnsAll ScoreTurns = "all"
+)
+
+// ParseScoreTurns parses the score_turns of a test case, final by default.
+func ParseScoreTurns(scoreTurns string) (ScoreTurns, error) {
+	switch ScoreTurns(scoreTurns) {
+	case "", ScoreTurnsFinal:
+		return ScoreTurnsFinal, nil
+	case ScoreTurnsAll:
+		return ScoreTurnsAll, nil
+	default:
+		return "", fmt.Errorf("unknown score_turns %q, expected final or all", scoreTurns)
+	}
+}
+
+// TurnResult is an answer of the model in a conversation. Expected is set for the scored answers.
+type TurnResult struct {
+	// Turn is the position of the answered user turn in the conversation, starting at 1
+	Turn     int      `json:"turn"`
+	Input    string   `json:"input"`
+	Output   string   `json:"output"`
+	Expected string   `json:"expected,omitempty"`
+	Metrics  []Metric `json:"metrics,omitempty"`
+}
+
+// AverageTurnMetrics averages every metric over the scored turns, keeping its weight.
+func AverageTurnMetrics(turns []TurnResult) []Metric {
+	var metrics []Metric
+	counts := map[string]int{}
+	for _, turn := range turns {
+		for _, metric := range turn.Metrics {
+			if counts[metric.Name] == 0 {
+				metrics = append(metrics, Metric{Name: metric.Name, Weight: metric.Weight})
+			}
+			counts[metric.Name]++
+			for i := range metrics {
+				if metrics[i].Name == metric.Name {
+					metrics[i].Value += metric.Value
+				}
+			}
+		}
+	}
+	for i := range metrics {
+		metrics[i].Value /= float64(counts[metrics[i].Name])
+	}
+	return metrics
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// DiagramMode controls how the diagram-as-code files of a test case are given to the model.
+type DiagramMode string
+
+const (
+	// DiagramModeSource appends the diagram source to the prompt, like a text document
+	DiagramModeSource DiagramMode = "source"
+	// DiagramModeImage renders the diagram to a PNG with a local command and attaches it as image
+	DiagramModeImage DiagramMode = "image"
+)
+
+func ParseDiagramMode(mode string) (DiagramMode, error) {
+	switch DiagramMode(mode) {
+	case "", DiagramModeSource:
+		return DiagramModeSource, nil
+	case DiagramModeImage:
+		return DiagramModeImage, nil
+	default:
+		return "", fmt.Errorf("unknown diagram mode %q, expected source or image", mode)
+	}
+}
+
+// DiagramKind is the language of a diagram-as-code file.
+type DiagramKind string
+
+const (
+	DiagramKindPlantUML    DiagramKind = "plantuml"
+	DiagramKindMermaid     DiagramKind = "mermaid"
+	DiagramKindStructurizr DiagramKind = "structurizr"
+)
+
+// diagramExtensions maps the file extensions to the diagram kinds.
+var diagramExtensions = map[string]DiagramKind{
+	".puml":     DiagramKindPlantUML,
+	".plantuml": DiagramKindPlantUML,
+	".pu":       DiagramKindPlantUML,
+	".mmd":      DiagramKindMermaid,
+	".mermaid":  DiagramKindMermaid,
+	".dsl":      DiagramKindStructurizr,
+}
+
+// DiagramKindOf returns the kind of a diagram file by its extension.
+func DiagramKindOf(path string) (DiagramKind, error) {
+	kind, ok := diagramExtensions[filepath.Ext(path)]
+	if !ok {
+		return "", fmt.Errorf("unknown diagram type of %s, expected .puml, .plantuml, .pu, .mmd, .mermaid or .dsl", filepath.Base(path))
+	}
+	return kind, nil
+}
+
+// DefaultDiagramRenderers are the render commands used when a test suite configures none. Structurizr
+// DSL has no local PNG renderer, it needs a configured command, e.g. a script around the Structurizr CLI.
+var DefaultDiagramRenderers = map[DiagramKind]string{
+	DiagramKindPlantUML: "plantuml -tpng -pipe",
+	DiagramKindMermaid:  "mmdc -i {input} -o {output}",
+}
+
+// DiagramConfig configures the diagram-as-code files of the test cases of a test suite. A render
+// command gets the diagram as {input} file or on stdin and writes the PNG to {output} or to stdout.
+type DiagramConfig struct {
+	Mode      DiagramMode            `json:"mode,omitempty"`
+	Renderers map[DiagramKind]string `json:"renderers,omitempty"`
+}
+
+// Renderer returns the render command of a diagram kind or "" if there is none.
+func (c DiagramConfig) Renderer(kind DiagramKind) string {
+	if renderer, ok := c.Renderers[kind]; ok {
+		return renderer
+	}
+	return DefaultDiagramRenderers[kind]
+}
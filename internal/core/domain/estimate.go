@@ -0,0 +1,56 @@
+package domain
+
+// CostEstimate is the projected token usage and cost of a set of LLM calls.
+type CostEstimate struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	GenerationCost   float64 `json:"generation_cost"`
+	EvaluationCost   float64 `json:"evaluation_cost"`
+	// UnknownCost is set when the price of at least one model is unknown, the cost is then incomplete.
+	UnknownCost bool `json:"unknown_cost"`
+}
+
+func (e CostEstimate) Add(other CostEstimate) CostEstimate {
+	return CostEstimate{
+		Calls:            e.Calls + other.Calls,
+		PromptTokens:     e.PromptTokens + other.PromptTokens,
+		CompletionTokens: e.CompletionTokens + other.CompletionTokens,
+		GenerationCost:   e.GenerationCost + other.GenerationCost,
+		EvaluationCost:   e.EvaluationCost + other.EvaluationCost,
+		UnknownCost:      e.UnknownCost || other.UnknownCost,
+	}
+}
+
+// Scale multiplies the estimate, e.g. by the number of repetitions.
+func (e CostEstimate) Scale(factor int) CostEstimate {
+	return CostEstimate{
+		Calls:            e.Calls * factor,
+		PromptTokens:     e.PromptTokens * factor,
+		CompletionTokens: e.CompletionTokens * factor,
+		GenerationCost:   e.GenerationCost * float64(factor),
+		EvaluationCost:   e.EvaluationCost * float64(factor),
+		UnknownCost:      e.UnknownCost,
+	}
+}
+
+func (e CostEstimate) TotalCost() float64 {
+	return e.GenerationCost + e.EvaluationCost
+}
+
+type TestSuiteEstimate struct {
+	Name      string       `json:"name"`
+	Provider  string       `json:"provider"`
+	Model     string       `json:"model"`
+	TestCases int          `json:"test_cases"`
+	Estimate  CostEstimate `json:"estimate"`
+	MaxCost   float64      `json:"max_cost,omitempty"`
+}
+
+type BenchmarkEstimate struct {
+	Name       string              `json:"name"`
+	EvalModel  string              `json:"eval_model"`
+	TestSuites []TestSuiteEstimate `json:"test_suites"`
+	Total      CostEstimate        `json:"total"`
+	MaxCost    float64             `json:"max_cost,omitempty"`
+}
@@ -0,0 +1,36 @@
+package domain
+
+// GenerationConfig holds the sampling parameters sent with a completion request.
+// Unset values (nil or empty) fall back to the provider defaults.
+type GenerationConfig struct {
+	Temperature     *float32 `json:"temperature,omitempty"`
+	TopP            *float32 `json:"top_p,omitempty"`
+	MaxTokens       *int     `json:"max_tokens,omitempty"`
+	Seed            *int     `json:"seed,omitempty"`
+	Stop            []string `json:"stop,omitempty"`
+	ReasoningEffort string   `json:"reasoning_effort,omitempty"`
+}
+
+// Merge returns a copy of the config with every value that is set in override replaced.
+func (g GenerationConfig) Merge(override GenerationConfig) GenerationConfig {
+	merged := g
+	if override.Temperature != nil {
+		merged.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		merged.TopP = override.TopP
+	}
+	if override.MaxTokens != nil {
+		merged.MaxTokens = override.MaxTokens
+	}
+	if override.Seed != nil {
+		merged.Seed = override.Seed
+	}
+	if len(override.Stop) > 0 {
+		merged.Stop = override.Stop
+	}
+	if override.ReasoningEffort != "" {
+		merged.ReasoningEffort = override.ReasoningEffort
+	}
+	return merged
+}
@@ -0,0 +1,32 @@
+package domain
+
+// Formats the images of a test suite can be converted to.
+const (
+	ImageFormatPNG  = "png"
+	ImageFormatJPEG = "jpeg"
+)
+
+// ImageConfig configures the preprocessing of the images of a test suite before they are sent.
+// Unset values fall back to the limits of the provider, larger values are capped by them.
+type ImageConfig struct {
+	// MaxSize is the maximum width and height in pixels, larger images are downscaled
+	MaxSize int `json:"max_size,omitempty"`
+	// MaxBytes is the maximum file size of an image, larger images are sent as JPEG and downscaled further
+	MaxBytes int `json:"max_bytes,omitempty"`
+	// Format converts every image to png or jpeg. By default only formats the provider does not accept are converted, to PNG.
+	Format string `json:"format,omitempty"`
+	// JPEGQuality is the quality of the JPEGs that are encoded, 1 to 100 (default 90)
+	JPEGQuality int `json:"jpeg_quality,omitempty"`
+	// Tile splits images that are larger than MaxSize into tiles of MaxSize instead of downscaling them.
+	// Images that would need more than MaxTiles tiles (default 4) are downscaled as far as needed.
+	Tile     bool `json:"tile,omitempty"`
+	MaxTiles int  `json:"max_tiles,omitempty"`
+}
+
+// ImageLimits are the limits of a provider for images, 0 means no limit.
+type ImageLimits struct {
+	MaxSize  int
+	MaxBytes int
+	// MimeTypes are the image formats the provider accepts
+	MimeTypes []string
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// CallRole describes why an LLM call was made.
+type CallRole string
+
+const (
+	CallRoleGeneration     CallRole = "generation"
+	CallRoleChainOfThought CallRole = "chain_of_thought"
+	CallRoleJudge          CallRole = "judge"
+)
+
+// IsEvaluation reports whether the call was made to evaluate a response rather than to generate it.
+func (r CallRole) IsEvaluation() bool {
+	return r != CallRoleGeneration
+}
+
+// LLMCall records a single call to a provider.
+type LLMCall struct {
+	Role        CallRole      `json:"role"`
+	Provider    string        `json:"provider"`
+	Model       string        `json:"model"`
+	Cost        float64       `json:"cost"`
+	CostUnknown bool          `json:"cost_unknown,omitempty"`
+	Usage       TokenUsage    `json:"usage"`
+	Duration    time.Duration `json:"duration"`
+	// Cached is set when the response was served from the response cache
+	Cached bool `json:"cached,omitempty"`
+	// Reused is set for the generation calls a rescored result keeps from the run it was scored from,
+	// they are not part of the cost and usage of the rescored run
+	Reused bool `json:"reused,omitempty"`
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LLMRequest is a request to a provider. History holds the previous messages of a conversation,
+// Query is the next user message. ToolMessages follow the query: the tool calls of the model and
+// their results, until the model answers without calling Tools.
+type LLMRequest struct {
+	SystemPrompt string
+	History      []Message
+	Query        string
+	Images       []Image
+	Documents    []Document
+	Tools        []Tool
+	ToolMessages []Message
+	Generation   GenerationConfig
+}
+
+// Image is a base64 encoded image attached to a request, with its size in pixels.
+type Image struct {
+	MimeType string
+	Data     string
+	Width    int
+	Height   int
+}
+
+// Document is a document attached to a request as context. Text documents carry only their Text.
+// PDFs carry their extracted Text and, if the provider reads PDFs natively, their base64 encoded Data.
+type Document struct {
+	Name     string
+	MimeType string
+	Data     string
+	Text     string
+}
+
+// Native reports whether the document is sent to the provider as file instead of as text.
+func (d Document) Native() bool {
+	return d.Data != ""
+}
+
+// Prompt returns the query followed by the text of the documents that are not sent as file.
+func (r LLMRequest) Prompt() string {
+	var prompt strings.Builder
+	prompt.WriteString(r.Query)
+	for _, document := range r.Documents {
+		if document.Native() {
+			continue
+		}
+		fmt.Fprintf(&prompt, "\n\n<document name=%q>\n%s\n</document>", document.Name, strings.TrimSpace(document.Text))
+	}
+	return prompt.String()
+}
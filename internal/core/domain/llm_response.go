@@ -3,4 +3,21 @@ package domain
 type LLMResponse struct {
 	Response string  `json:"response"`
 	Cost     float64 `json:"cost"`
+	// CostUnknown is set when the provider has no price for the model, Cost is 0 then.
+	CostUnknown bool       `json:"cost_unknown,omitempty"`
+	Usage       TokenUsage `json:"usage"`
+	// Generation holds the parameters the provider actually applied to the request.
+	Generation GenerationConfig `json:"generation"`
+	// Cached is set when the response was served from the response cache, Cost is 0 then.
+	Cached bool `json:"cached,omitempty"`
+	// ToolCalls are the tools the model calls instead of answering, Response may be empty then.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+type StructuredResponse struct {
+	Data        map[string]interface{} `json:"data"`
+	Cost        float64                `json:"cost"`
+	CostUnknown bool                   `json:"cost_unknown,omitempty"`
+	Usage       TokenUsage             `json:"usage"`
+	Cached      bool                   `json:"cached,omitempty"`
 }
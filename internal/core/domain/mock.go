@@ -0,0 +1,34 @@
+package domain
+
+// Errors the mock provider can inject.
+const (
+	MockErrorRateLimit     = "rate_limit"
+	MockErrorServerError   = "server_error"
+	MockErrorBadRequest    = "bad_request"
+	MockErrorTimeout       = "timeout"
+	MockErrorMalformedJSON = "malformed_json"
+)
+
+// MockConfig scripts the responses of the mock provider for a test case. Output, Error and
+// ErrorCount apply to text responses (the generation and the chain of thoughts), Score and
+// JudgeError to the structured responses of the judge.
+type MockConfig struct {
+	Output           string   `json:"output"`
+	Score            *float64 `json:"score"`
+	LatencyMs        int      `json:"latency_ms"`
+	Cost             float64  `json:"cost"`
+	PromptTokens     int      `json:"prompt_tokens"`
+	CompletionTokens int      `json:"completion_tokens"`
+	Error            string   `json:"error"`
+	// ErrorCount limits the injected errors to the first attempts of every request, 0 fails all of them
+	ErrorCount int    `json:"error_count"`
+	JudgeError string `json:"judge_error"`
+	// ToolCalls are requested one per response when the request offers tools, before the output is returned
+	ToolCalls []MockToolCall `json:"tool_calls"`
+}
+
+// MockToolCall is a tool call the mock provider requests.
+type MockToolCall struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+}
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+const PriceDateFormat = "2006-01-02"
+
+// ModelPrice holds the price of a model in USD per million tokens, valid from its effective date on.
+type ModelPrice struct {
+	Provider                  string  `json:"provider" yaml:"provider"`
+	Model                     string  `json:"model" yaml:"model"`
+	InputCostPerMillion       float64 `json:"input_per_million" yaml:"input_per_million"`
+	CachedInputCostPerMillion float64 `json:"cached_input_per_million,omitempty" yaml:"cached_input_per_million,omitempty"`
+	OutputCostPerMillion      float64 `json:"output_per_million" yaml:"output_per_million"`
+	EffectiveDate             string  `json:"effective_date" yaml:"effective_date"`
+}
+
+// Cost calculates the cost of the token usage. Cached prompt tokens are billed at the cached input
+// rate, or at the input rate if the model has no cached rate.
+func (p ModelPrice) Cost(usage TokenUsage) float64 {
+	cachedRate := p.CachedInputCostPerMillion
+	if cachedRate == 0 {
+		cachedRate = p.InputCostPerMillion
+	}
+
+	uncachedTokens := usage.PromptTokens - usage.CachedTokens
+	promptCost := (float64(uncachedTokens)/1000000)*p.InputCostPerMillion + (float64(usage.CachedTokens)/1000000)*cachedRate
+	completionCost := (float64(usage.CompletionTokens) / 1000000) * p.OutputCostPerMillion
+
+	return promptCost + completionCost
+}
+
+type PricingCatalog struct {
+	Version string       `json:"version" yaml:"version"`
+	Prices  []ModelPrice `json:"prices" yaml:"prices"`
+}
+
+// Lookup returns the price of the model that is effective at the given time.
+func (c *PricingCatalog) Lookup(provider, model string, at time.Time) (ModelPrice, bool) {
+	var found ModelPrice
+	var foundDate time.Time
+	ok := false
+
+	for _, price := range c.Prices {
+		if price.Provider != provider || price.Model != model {
+			continue
+		}
+		effective, err := time.Parse(PriceDateFormat, price.EffectiveDate)
+		if err != nil || effective.After(at) {
+			continue
+		}
+		// Later entries win on equal dates so overrides replace the defaults
+		if !ok || !effective.Before(foundDate) {
+			found, foundDate, ok = price, effective, true
+		}
+	}
+
+	return found, ok
+}
+
+// Merge returns a catalog with the prices of other appended, so they take precedence over equally dated prices.
+func (c *PricingCatalog) Merge(other *PricingCatalog) *PricingCatalog {
+	merged := &PricingCatalog{
+		Version: c.Version,
+		Prices:  append(append([]ModelPrice{}, c.Prices...), other.Prices...),
+	}
+	if other.Version != "" {
+		merged.Version = other.Version
+	}
+	return merged
+}
+
+// Models returns the sorted names of all models of a provider in the catalog.
+func (c *PricingCatalog) Models(provider string) []string {
+	seen := map[string]bool{}
+	var models []string
+	for _, price := range c.Prices {
+		if price.Provider == provider && !seen[price.Model] {
+			seen[price.Model] = true
+			models = append(models, price.Model)
+		}
+	}
+	sort.Strings(models)
+	return models
+}
@@ -0,0 +1,17 @@
+package domain
+
+// DefaultRepositoryTokens is the token budget of a packed repository unless max_tokens is set.
+const DefaultRepositoryTokens = 32000
+
+// RepositoryConfig packs a local source tree into the prompt of a test case: its file tree and the
+// content of the included files, within a token budget.
+type RepositoryConfig struct {
+	// Path is the root of the source tree, relative to the test case
+	Path string `json:"path"`
+	// Include are the globs of the files whose content is packed, in the order of priority. All
+	// text files are packed if empty.
+	Include []string `json:"include"`
+	// Exclude are the globs of the files that are left out of the file tree and the contents
+	Exclude   []string `json:"exclude"`
+	MaxTokens int      `json:"max_tokens"`
+}
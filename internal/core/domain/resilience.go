@@ -0,0 +1,59 @@
+package domain
+
+import "time"
+
+// ResilienceConfig configures retries, rate limits and the circuit breaker of a provider.
+// Zero values fall back to the defaults, rate limits are off unless they are set.
+type ResilienceConfig struct {
+	MaxRetries               int `json:"max_retries"`
+	InitialBackoffMs         int `json:"initial_backoff_ms"`
+	MaxBackoffMs             int `json:"max_backoff_ms"`
+	RequestsPerMinute        int `json:"requests_per_minute"`
+	TokensPerMinute          int `json:"tokens_per_minute"`
+	CircuitBreakerThreshold  int `json:"circuit_breaker_threshold"`
+	CircuitBreakerCooldownMs int `json:"circuit_breaker_cooldown_ms"`
+}
+
+var DefaultResilienceConfig = ResilienceConfig{
+	MaxRetries:               3,
+	InitialBackoffMs:         1000,
+	MaxBackoffMs:             30000,
+	CircuitBreakerThreshold:  5,
+	CircuitBreakerCooldownMs: 60000,
+}
+
+// WithDefaults returns a copy of the config with every unset value taken from DefaultResilienceConfig.
+// A negative MaxRetries disables retries.
+func (c ResilienceConfig) WithDefaults() ResilienceConfig {
+	if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultResilienceConfig.MaxRetries
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	}
+	if c.InitialBackoffMs == 0 {
+		c.InitialBackoffMs = DefaultResilienceConfig.InitialBackoffMs
+	}
+	if c.MaxBackoffMs == 0 {
+		c.MaxBackoffMs = DefaultResilienceConfig.MaxBackoffMs
+	}
+	if c.CircuitBreakerThreshold == 0 {
+		c.CircuitBreakerThreshold = DefaultResilienceConfig.CircuitBreakerThreshold
+	}
+	if c.CircuitBreakerCooldownMs == 0 {
+		c.CircuitBreakerCooldownMs = DefaultResilienceConfig.CircuitBreakerCooldownMs
+	}
+	return c
+}
+
+func (c ResilienceConfig) InitialBackoff() time.Duration {
+	return time.Duration(c.InitialBackoffMs) * time.Millisecond
+}
+
+func (c ResilienceConfig) MaxBackoff() time.Duration {
+	return time.Duration(c.MaxBackoffMs) * time.Millisecond
+}
+
+func (c ResilienceConfig) CircuitBreakerCooldown() time.Duration {
+	return time.Duration(c.CircuitBreakerCooldownMs) * time.Millisecond
+}
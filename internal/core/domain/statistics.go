@@ -0,0 +1,188 @@
+package domain
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	DefaultConfidenceLevel     = 0.95
+	DefaultBootstrapIterations = 10000
+	// A fixed seed keeps the reported intervals reproducible between report runs.
+	bootstrapSeed = 42
+)
+
+type ConfidenceInterval struct {
+	Mean  float64 `json:"mean"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Level float64 `json:"level"`
+	N     int     `json:"n"`
+}
+
+// PairedComparison holds the result of comparing two paired samples (candidate - baseline).
+type PairedComparison struct {
+	Name            string             `json:"name"`
+	N               int                `json:"n"`
+	BaselineMean    float64            `json:"baseline_mean"`
+	CandidateMean   float64            `json:"candidate_mean"`
+	MeanDifference  ConfidenceInterval `json:"mean_difference"`
+	BootstrapPValue float64            `json:"bootstrap_p_value"`
+	WilcoxonW       float64            `json:"wilcoxon_w"`
+	WilcoxonPValue  float64            `json:"wilcoxon_p_value"`
+}
+
+func (c PairedComparison) IsSignificant(alpha float64) bool {
+	return c.N > 0 && c.BootstrapPValue < alpha && c.WilcoxonPValue < alpha
+}
+
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// BootstrapConfidenceInterval estimates a percentile bootstrap confidence interval for the mean.
+func BootstrapConfidenceInterval(values []float64, level float64, iterations int) ConfidenceInterval {
+	ci := ConfidenceInterval{Mean: Mean(values), Level: level, N: len(values)}
+	if len(values) < 2 {
+		ci.Lower, ci.Upper = ci.Mean, ci.Mean
+		return ci
+	}
+
+	means := bootstrapMeans(values, iterations)
+	ci.Lower, ci.Upper = percentileInterval(means, level)
+	return ci
+}
+
+// ComparePaired compares a candidate sample against a baseline sample of paired observations
+// using a paired bootstrap on the differences and a Wilcoxon signed-rank test.
+func ComparePaired(name string, baseline, candidate []float64) PairedComparison {
+	n := len(baseline)
+	if len(candidate) < n {
+		n = len(candidate)
+	}
+	diffs := make([]float64, n)
+	for i := 0; i < n; i++ {
+		diffs[i] = candidate[i] - baseline[i]
+	}
+
+	comparison := PairedComparison{
+		Name:            name,
+		N:               n,
+		BaselineMean:    Mean(baseline[:n]),
+		CandidateMean:   Mean(candidate[:n]),
+		MeanDifference:  BootstrapConfidenceInterval(diffs, DefaultConfidenceLevel, DefaultBootstrapIterations),
+		BootstrapPValue: 1,
+		WilcoxonPValue:  1,
+	}
+	if n < 2 {
+		return comparison
+	}
+
+	comparison.BootstrapPValue = pairedBootstrapPValue(diffs, DefaultBootstrapIterations)
+	comparison.WilcoxonW, comparison.WilcoxonPValue = WilcoxonSignedRank(diffs)
+	return comparison
+}
+
+// pairedBootstrapPValue returns the two-sided p-value for the null hypothesis that the mean difference is zero.
+func pairedBootstrapPValue(diffs []float64, iterations int) float64 {
+	observed := Mean(diffs)
+	centered := make([]float64, len(diffs))
+	for i, d := range diffs {
+		centered[i] = d - observed
+	}
+
+	extreme := 0
+	for _, m := range bootstrapMeans(centered, iterations) {
+		if math.Abs(m) >= math.Abs(observed) {
+			extreme++
+		}
+	}
+	return float64(extreme+1) / float64(iterations+1)
+}
+
+// WilcoxonSignedRank returns the W statistic and the two-sided p-value (normal approximation
+// with tie and continuity correction) for the given paired differences. Zero differences are dropped.
+func WilcoxonSignedRank(diffs []float64) (float64, float64) {
+	type rankedDiff struct {
+		abs  float64
+		sign float64
+	}
+
+	var nonZero []rankedDiff
+	for _, d := range diffs {
+		if d != 0 {
+			nonZero = append(nonZero, rankedDiff{abs: math.Abs(d), sign: math.Copysign(1, d)})
+		}
+	}
+	n := len(nonZero)
+	if n == 0 {
+		return 0, 1
+	}
+	sort.Slice(nonZero, func(i, j int) bool { return nonZero[i].abs < nonZero[j].abs })
+
+	var wPlus, wMinus, tieCorrection float64
+	for i := 0; i < n; {
+		j := i
+		for j < n && nonZero[j].abs == nonZero[i].abs {
+			j++
+		}
+		// Tied values share the average of the ranks they span
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if nonZero[k].sign > 0 {
+				wPlus += rank
+			} else {
+				wMinus += rank
+			}
+		}
+		t := float64(j - i)
+		tieCorrection += t*t*t - t
+		i = j
+	}
+
+	w := math.Min(wPlus, wMinus)
+	nf := float64(n)
+	mean := nf * (nf + 1) / 4
+	variance := nf*(nf+1)*(2*nf+1)/24 - tieCorrection/48
+	if variance <= 0 {
+		return w, 1
+	}
+
+	z := (math.Abs(w-mean) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		z = 0
+	}
+	p := math.Erfc(z / math.Sqrt2)
+	return w, math.Min(p, 1)
+}
+
+func bootstrapMeans(values []float64, iterations int) []float64 {
+	rng := rand.New(rand.NewSource(bootstrapSeed))
+	means := make([]float64, iterations)
+	n := len(values)
+	for i := range means {
+		var sum float64
+		for j := 0; j < n; j++ {
+			sum += values[rng.Intn(n)]
+		}
+		means[i] = sum / float64(n)
+	}
+	return means
+}
+
+func percentileInterval(samples []float64, level float64) (float64, float64) {
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	alpha := (1 - level) / 2
+	lower := sorted[int(math.Floor(alpha*float64(len(sorted)-1)))]
+	upper := sorted[int(math.Ceil((1-alpha)*float64(len(sorted)-1)))]
+	return lower, upper
+}
@@ -0,0 +1,23 @@
+package domain
+
+// ErrorCategory tells in which step a test case failed.
+type ErrorCategory string
+
+const (
+	// ErrorCategoryConfiguration is an invalid benchmark setup, e.g. an unsupported provider or a missing API key
+	ErrorCategoryConfiguration ErrorCategory = "configuration"
+	// ErrorCategoryInput is an input of the test case that cannot be read, e.g. a missing image
+	ErrorCategoryInput ErrorCategory = "input"
+	// ErrorCategoryProvider is a failed call of the model under test
+	ErrorCategoryProvider ErrorCategory = "provider"
+	// ErrorCategoryModel is a model under test that breaks a limit of the test case, e.g. more tool calls than allowed
+	ErrorCategoryModel ErrorCategory = "model"
+	// ErrorCategoryEvaluation is a failed evaluation, e.g. a judge response that cannot be parsed
+	ErrorCategoryEvaluation ErrorCategory = "evaluation"
+)
+
+// TestError records why a repetition of a test case failed.
+type TestError struct {
+	Category ErrorCategory `json:"category"`
+	Message  string        `json:"message"`
+}
@@ -1,47 +1,298 @@
 package domain
 
-import "time"
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// DefaultPassThreshold is the average rating a repetition needs to count as passed.
+const DefaultPassThreshold = 50.0
 
 type TestCaseConfig struct {
-	Name     string
-	Path     string
-	Input    string
-	Expected string
-	Images   []string
+	Name        string
+	Path        string
+	Input       string
+	Expected    string
+	Images      []string
+	Documents   []string
+	Diagrams    []string
+	Repetitions int
+	Tags        []string         `json:"tags"`
+	Generation  GenerationConfig `json:"generation"`
+	// MetricConfigs replace the metrics of the test suite for this test case
+	MetricConfigs []MetricConfig `json:"metrics"`
+	// Mock scripts the responses of the mock provider
+	Mock *MockConfig `json:"mock,omitempty"`
+	// Conversation replaces the input with a scripted conversation, ScoreTurns selects the scored answers
+	Conversation []Turn     `json:"conversation"`
+	ScoreTurns   ScoreTurns `json:"score_turns"`
+	// Tools gives the model read-only tools on a fixture directory
+	Tools *ToolConfig `json:"tools,omitempty"`
+	// Repository packs a source tree into the prompt
+	Repository *RepositoryConfig `json:"repository,omitempty"`
 }
 
 type TestCase struct {
-	Name     string
-	Input    string
-	Expected string
-	Result   *TestResult
+	Name              string             `json:"name"`
+	Input             string             `json:"input"`
+	Expected          string             `json:"expected"`
+	Tags              []string           `json:"tags,omitempty"`
+	Conversation      []Turn             `json:"conversation,omitempty"`
+	ExpectedToolCalls []ExpectedToolCall `json:"expected_tool_calls,omitempty"`
+	PassThreshold     float64            `json:"pass_threshold"`
+	Results           []*TestResult      `json:"results"`
+}
+
+// UnmarshalJSON also reads reports written before repetitions, whose test cases have a single
+// result whose cost is the cost of the generation.
+func (tc *TestCase) UnmarshalJSON(data []byte) error {
+	type testCase TestCase
+	var decoded struct {
+		testCase
+		Result *struct {
+			TestResult
+			Cost float64 `json:"cost"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*tc = TestCase(decoded.testCase)
+	if decoded.Result != nil && len(tc.Results) == 0 {
+		result := decoded.Result.TestResult
+		result.Repetition = 1
+		result.GenerationCost = decoded.Result.Cost
+		tc.Results = []*TestResult{&result}
+	}
+	return nil
 }
 
 type TestResult struct {
-	Output   string
-	Metrics  []Metric
-	Duration time.Duration
-	Cost     float64
+	Repetition int           `json:"repetition"`
+	Output     string        `json:"output"`
+	Metrics    []Metric      `json:"metrics"`
+	Duration   time.Duration `json:"duration"`
+	// GenerationCost is the cost of the model under test, EvaluationCost the cost of judging its output.
+	GenerationCost float64 `json:"generation_cost"`
+	EvaluationCost float64 `json:"evaluation_cost"`
+	// CostUnknown is set when the price of at least one call is unknown, the costs are incomplete then.
+	CostUnknown     bool       `json:"cost_unknown,omitempty"`
+	Usage           TokenUsage `json:"usage"`
+	EvaluationUsage TokenUsage `json:"evaluation_usage"`
+	Calls           []LLMCall  `json:"calls"`
+	// Generation holds the generation parameters that were actually applied.
+	Generation GenerationConfig `json:"generation"`
+	// Error is set when the repetition failed, it has no rating then.
+	Error *TestError `json:"error,omitempty"`
+	// Turns holds the answers of a conversation, Output is the final answer then
+	Turns []TurnResult `json:"turns,omitempty"`
+	// ToolCalls is the trajectory of a test case with tools, the tool calls in the order they were made
+	ToolCalls []ToolCallRecord `json:"tool_calls,omitempty"`
 }
 
-func (tc *TestCase) CalculateAverageRating() float64 {
-	if tc.Result == nil || len(tc.Result.Metrics) == 0 {
+type RatingStatistics struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	N      int     `json:"n"`
+}
+
+// AddCall records an LLM call and adds its cost and token usage to the generation or evaluation
+// totals. Reused calls are only recorded.
+func (tr *TestResult) AddCall(call LLMCall) {
+	tr.Calls = append(tr.Calls, call)
+	if call.Reused {
+		return
+	}
+	tr.CostUnknown = tr.CostUnknown || call.CostUnknown
+	if call.Role.IsEvaluation() {
+		tr.EvaluationCost += call.Cost
+		tr.EvaluationUsage = tr.EvaluationUsage.Add(call.Usage)
+	} else {
+		tr.GenerationCost += call.Cost
+		tr.Usage = tr.Usage.Add(call.Usage)
+	}
+}
+
+func (tr *TestResult) Failed() bool {
+	return tr.Error != nil
+}
+
+func (tr *TestResult) TotalCost() float64 {
+	return tr.GenerationCost + tr.EvaluationCost
+}
+
+// CalculateAverageRating returns the weighted average of the metrics. Metrics without a weight count
+// once, which keeps the ratings of results from before the weights were recorded unchanged.
+func (tr *TestResult) CalculateAverageRating() float64 {
+	if tr == nil || len(tr.Metrics) == 0 {
 		return 0
 	}
 
 	var sum float64
-	var count int
+	var totalWeight float64
 
-	for _, metric := range tc.Result.Metrics {
+	for _, metric := range tr.Metrics {
 		if metric.Name != "duration" && metric.Name != "cost" {
-			sum += metric.Value
-			count++
+			weight := metric.Weight
+			if weight == 0 {
+				weight = 1
+			}
+			sum += weight * metric.Value
+			totalWeight += weight
 		}
 	}
 
-	if count == 0 {
+	if totalWeight == 0 {
 		return 0
 	}
 
-	return sum / float64(count)
+	return sum / totalWeight
+}
+
+// CalculateAverageRating returns the mean rating over all repetitions of the test case.
+func (tc *TestCase) CalculateAverageRating() float64 {
+	return Mean(tc.Ratings())
+}
+
+// Ratings returns the rating of every repetition that did not fail.
+func (tc *TestCase) Ratings() []float64 {
+	ratings := make([]float64, 0, len(tc.Results))
+	for _, result := range tc.Results {
+		if !result.Failed() {
+			ratings = append(ratings, result.CalculateAverageRating())
+		}
+	}
+	return ratings
+}
+
+// Errors counts the failed repetitions.
+func (tc *TestCase) Errors() int {
+	errors := 0
+	for _, result := range tc.Results {
+		if result.Failed() {
+			errors++
+		}
+	}
+	return errors
+}
+
+func (tc *TestCase) RatingStatistics() RatingStatistics {
+	ratings := tc.Ratings()
+	stats := RatingStatistics{Mean: Mean(ratings), N: len(ratings)}
+	if len(ratings) == 0 {
+		return stats
+	}
+
+	stats.Min, stats.Max = ratings[0], ratings[0]
+	var squares float64
+	for _, rating := range ratings {
+		stats.Min = math.Min(stats.Min, rating)
+		stats.Max = math.Max(stats.Max, rating)
+		squares += (rating - stats.Mean) * (rating - stats.Mean)
+	}
+	if len(ratings) > 1 {
+		stats.StdDev = math.Sqrt(squares / float64(len(ratings)-1))
+	}
+	return stats
+}
+
+// Passes counts the repetitions whose rating reaches the pass threshold. Failed repetitions never pass.
+func (tc *TestCase) Passes() int {
+	threshold := tc.PassThreshold
+	if threshold == 0 {
+		threshold = DefaultPassThreshold
+	}
+
+	passes := 0
+	for _, rating := range tc.Ratings() {
+		if rating >= threshold {
+			passes++
+		}
+	}
+	return passes
+}
+
+// PassAtK is the unbiased estimate of the probability that at least one of k samples passes.
+func (tc *TestCase) PassAtK(k int) float64 {
+	return PassAtK(len(tc.Results), tc.Passes(), k)
+}
+
+// PassHatK is the estimated probability that all of k samples pass, a measure of consistency.
+func (tc *TestCase) PassHatK(k int) float64 {
+	return PassHatK(len(tc.Results), tc.Passes(), k)
+}
+
+func (tc *TestCase) Duration() time.Duration {
+	var duration time.Duration
+	for _, result := range tc.Results {
+		duration += result.Duration
+	}
+	return duration
+}
+
+func (tc *TestCase) Usage() TokenUsage {
+	var usage TokenUsage
+	for _, result := range tc.Results {
+		usage = usage.Add(result.Usage)
+	}
+	return usage
+}
+
+func (tc *TestCase) GenerationCost() float64 {
+	var cost float64
+	for _, result := range tc.Results {
+		cost += result.GenerationCost
+	}
+	return cost
+}
+
+func (tc *TestCase) EvaluationCost() float64 {
+	var cost float64
+	for _, result := range tc.Results {
+		cost += result.EvaluationCost
+	}
+	return cost
+}
+
+func (tc *TestCase) CostUnknown() bool {
+	for _, result := range tc.Results {
+		if result.CostUnknown {
+			return true
+		}
+	}
+	return false
+}
+
+func (tc *TestCase) Cost() float64 {
+	return tc.GenerationCost() + tc.EvaluationCost()
+}
+
+// PassAtK computes 1 - C(n-c, k) / C(n, k) for n samples with c passes.
+func PassAtK(n, c, k int) float64 {
+	if n == 0 || k <= 0 || k > n {
+		return 0
+	}
+	if n-c < k {
+		return 1
+	}
+	prob := 1.0
+	for i := n - c + 1; i <= n; i++ {
+		prob *= 1 - float64(k)/float64(i)
+	}
+	return 1 - prob
+}
+
+// PassHatK computes C(c, k) / C(n, k) for n samples with c passes.
+func PassHatK(n, c, k int) float64 {
+	if n == 0 || k <= 0 || k > n || c < k {
+		return 0
+	}
+	prob := 1.0
+	for i := 0; i < k; i++ {
+		prob *= float64(c-i) / float64(n-i)
+	}
+	return prob
 }
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTestCaseUnmarshalLegacyResult(t *testing.T) {
+	data := `{"name": "layers", "input": "in", "expected": "out", "result": {"output": "answer", "metrics": [{"name": "geval", "value": 80}], "cost": 0.25}}`
+
+	var testCase TestCase
+	if err := json.Unmarshal([]byte(data), &testCase); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if testCase.Name != "layers" || testCase.Expected != "out" {
+		t.Errorf("test case fields not decoded: %+v", testCase)
+	}
+	if len(testCase.Results) != 1 {
+		t.Fatalf("got %d results, want 1", len(testCase.Results))
+	}
+	result := testCase.Results[0]
+	if result.Repetition != 1 || result.Output != "answer" || result.GenerationCost != 0.25 {
+		t.Errorf("legacy result decoded as %+v", result)
+	}
+	if got := testCase.CalculateAverageRating(); got != 80 {
+		t.Errorf("rating = %v, want 80", got)
+	}
+}
+
+func TestTestCaseUnmarshalResults(t *testing.T) {
+	data := `{"name": "layers", "results": [{"repetition": 1, "output": "a"}, {"repetition": 2, "output": "b"}]}`
+
+	var testCase TestCase
+	if err := json.Unmarshal([]byte(data), &testCase); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(testCase.Results) != 2 || testCase.Results[1].Output != "b" {
+		t.Errorf("results decoded as %+v", testCase.Results)
+	}
+}
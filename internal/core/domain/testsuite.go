@@ -5,23 +5,38 @@ import (
 )
 
 type TestSuite struct {
-	Name      string
-	Metrics   []Metric
-	TestCases []TestCase
+	Name      string     `json:"name"`
+	Provider  string     `json:"provider"`
+	Model     string     `json:"model"`
+	Metrics   []Metric   `json:"metrics,omitempty"`
+	TestCases []TestCase `json:"test_cases"`
 }
 
 type TestSuiteConfig struct {
-	Name            string
-	Description     string
-	Provider        string
-	Model           string
-	MetricConfigs   []MetricConfig
+	Name          string
+	Description   string
+	Provider      string
+	Model         string
+	Repetitions   int
+	PassThreshold float64          `json:"pass_threshold"`
+	Generation    GenerationConfig `json:"generation"`
+	MaxCost       float64          `json:"max_cost"`
+	// Diagrams decides whether the diagram-as-code files of the test cases are sent as source or image
+	Diagrams DiagramConfig `json:"diagrams"`
+	// ImageProcessing configures how the images of the test cases are scaled, tiled and converted
+	ImageProcessing ImageConfig `json:"image_processing"`
+	// Cases is a JSONL or CSV file in the test suite directory with more test cases
+	Cases           string         `json:"cases"`
+	MetricConfigs   []MetricConfig `json:"metrics"`
 	TestCaseConfigs []TestCaseConfig
+	// Path is the test suite directory, relative render commands are resolved against it
+	Path string `json:"-"`
 }
 
 type Metric struct {
-	Name  string
-	Value float64
+	Name   string  `json:"name"`
+	Value  float64 `json:"value"`
+	Weight float64 `json:"weight,omitempty"`
 }
 
 type Provider struct {
@@ -29,26 +44,143 @@ type Provider struct {
 	Model string
 }
 
+// KnownProviders are the providers a test suite can run against.
+var KnownProviders = []string{"openai", "mock"}
+
 type TestSuiteResult struct {
-	TestSuite     string
-	TestCase      string
-	Duration      time.Duration
-	Cost          float64
-	AverageRating float64
+	TestSuite      string
+	TestCase       string
+	Repetitions    int
+	Duration       time.Duration
+	Cost           float64
+	GenerationCost float64
+	EvaluationCost float64
+	CostUnknown    bool
+	Errors         int
+	Usage          TokenUsage
+	AverageRating  float64
+	Rating         RatingStatistics
+	PassAtK        float64
+	PassHatK       float64
+}
+
+// Metrics returns the configured metrics of the test suite or the default metrics.
+func (c *TestSuiteConfig) Metrics() []MetricConfig {
+	if len(c.MetricConfigs) == 0 {
+		return DefaultMetricConfigs
+	}
+	return c.MetricConfigs
+}
+
+// CaseMetrics returns the metrics of a test case: its own metrics if it overrides them, the metrics
+// of the test suite otherwise.
+func (c *TestSuiteConfig) CaseMetrics(testCaseConfig *TestCaseConfig) []MetricConfig {
+	if testCaseConfig != nil && len(testCaseConfig.MetricConfigs) > 0 {
+		return testCaseConfig.MetricConfigs
+	}
+	return c.Metrics()
+}
+
+// TestCaseConfig returns the configuration of the named test case or nil. It is safe to call on a nil config.
+func (c *TestSuiteConfig) TestCaseConfig(name string) *TestCaseConfig {
+	if c == nil {
+		return nil
+	}
+	for i := range c.TestCaseConfigs {
+		if c.TestCaseConfigs[i].Name == name {
+			return &c.TestCaseConfigs[i]
+		}
+	}
+	return nil
 }
 
 func (ts *TestSuite) AggregateResults() []TestSuiteResult {
 	results := make([]TestSuiteResult, len(ts.TestCases))
 
 	for i, testCase := range ts.TestCases {
+		repetitions := len(testCase.Results)
 		results[i] = TestSuiteResult{
-			TestSuite:     ts.Name,
-			TestCase:      testCase.Name,
-			Duration:      testCase.Result.Duration,
-			Cost:          testCase.Result.Cost,
-			AverageRating: testCase.CalculateAverageRating(),
+			TestSuite:      ts.Name,
+			TestCase:       testCase.Name,
+			Repetitions:    repetitions,
+			Duration:       testCase.Duration(),
+			Cost:           testCase.Cost(),
+			GenerationCost: testCase.GenerationCost(),
+			EvaluationCost: testCase.EvaluationCost(),
+			CostUnknown:    testCase.CostUnknown(),
+			Errors:         testCase.Errors(),
+			Usage:          testCase.Usage(),
+			AverageRating:  testCase.CalculateAverageRating(),
+			Rating:         testCase.RatingStatistics(),
+			PassAtK:        testCase.PassAtK(repetitions),
+			PassHatK:       testCase.PassHatK(repetitions),
 		}
 	}
 
 	return results
 }
+
+func (ts *TestSuite) Usage() TokenUsage {
+	var usage TokenUsage
+	for i := range ts.TestCases {
+		usage = usage.Add(ts.TestCases[i].Usage())
+	}
+	return usage
+}
+
+func (ts *TestSuite) GenerationCost() float64 {
+	var cost float64
+	for i := range ts.TestCases {
+		cost += ts.TestCases[i].GenerationCost()
+	}
+	return cost
+}
+
+func (ts *TestSuite) EvaluationCost() float64 {
+	var cost float64
+	for i := range ts.TestCases {
+		cost += ts.TestCases[i].EvaluationCost()
+	}
+	return cost
+}
+
+func (ts *TestSuite) CostUnknown() bool {
+	for i := range ts.TestCases {
+		if ts.TestCases[i].CostUnknown() {
+			return true
+		}
+	}
+	return false
+}
+
+// Ratings returns the average rating of every test case, test cases that failed in every repetition are left out.
+func (ts *TestSuite) Ratings() []float64 {
+	ratings := make([]float64, 0, len(ts.TestCases))
+	for i := range ts.TestCases {
+		if len(ts.TestCases[i].Ratings()) > 0 {
+			ratings = append(ratings, ts.TestCases[i].CalculateAverageRating())
+		}
+	}
+	return ratings
+}
+
+func (ts *TestSuite) Errors() int {
+	errors := 0
+	for i := range ts.TestCases {
+		errors += ts.TestCases[i].Errors()
+	}
+	return errors
+}
+
+func (ts *TestSuite) RatingConfidenceInterval() ConfidenceInterval {
+	return BootstrapConfidenceInterval(ts.Ratings(), DefaultConfidenceLevel, DefaultBootstrapIterations)
+}
+
+func (ts *TestSuite) TestCase(name string) *TestCase {
+	for i := range ts.TestCases {
+		if ts.TestCases[i].Name == name {
+			return &ts.TestCases[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package domain
+
+// DefaultMaxToolCalls limits the tool calls of a repetition unless the test case sets max_calls.
+const DefaultMaxToolCalls = 20
+
+// Tool is a function the model can call, Parameters is the JSON schema of its arguments.
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+// ToolCall is a call of a tool requested by the model, Arguments is a JSON object.
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolConfig gives a test case read-only tools on a fixture directory, e.g. a small repository.
+type ToolConfig struct {
+	// Fixture is the directory the tools work on, relative to the test case
+	Fixture string `json:"fixture"`
+	// Allow are the names of the built-in tools the model gets, all of them if empty
+	Allow []string `json:"allow"`
+	// MaxCalls ends the tool loop with an error after this many calls (default DefaultMaxToolCalls)
+	MaxCalls int `json:"max_calls"`
+	// ExpectedCalls is the expected trajectory, scored by the tool_trajectory metric
+	ExpectedCalls []ExpectedToolCall `json:"expected_calls"`
+}
+
+// ExpectedToolCall is a tool call the model is expected to make. Only the given arguments are
+// compared, e.g. {"path": "go.mod"}, others are ignored.
+type ExpectedToolCall struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// ToolCallRecord is a tool call of the model together with its result, Output is shortened to
+// the beginning of the result.
+type ToolCallRecord struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Output    string `json:"output"`
+	// Error is set when the tool failed, the model got the error as result then
+	Error string `json:"error,omitempty"`
+}
+
+// Trajectory is the tool use the tool_trajectory metric scores: the calls the model made and the
+// calls expected of it.
+type Trajectory struct {
+	Calls    []ToolCallRecord
+	Expected []ExpectedToolCall
+}
@@ -1,9 +1,13 @@
 package ports
 
-import "github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+import (
+	"context"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
 
 type LLMProvider interface {
-	GenerateResponse(systemPrompt string, query string, images []string) (domain.LLMResponse, error)
+	GenerateResponse(ctx context.Context, request domain.LLMRequest) (domain.LLMResponse, error)
 	GetModels() []string
-	GenerateStructuredResponse(systemPrompt string, query string, schema domain.StructuredOutput) (map[string]interface{}, error)
+	GenerateStructuredResponse(ctx context.Context, request domain.LLMRequest, schema domain.StructuredOutput) (domain.StructuredResponse, error)
 }
@@ -1,18 +1,63 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/diagram"
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/llm"
 	"github.com/ingo-eichhorst/arch-bench/internal/adapters/report"
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/repository"
 	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
 	"github.com/ingo-eichhorst/arch-bench/internal/core/ports"
 )
 
 type BenchmarkService struct {
 	cfg           *domain.BenchmarkConfig
+	providers     *ProviderFactory
 	metricService *MetricService
+	estimator     *CostEstimator
+	// repositories are the packed repositories of the test cases, shared with the estimator
+	repositories *repositoryPacks
+	// spent is the cost of the current run so far, checked against the configured budgets
+	spent float64
+	// unpricedSuites are the test suites that were warned about models with an unknown price
+	unpricedSuites map[string]bool
+}
+
+// BudgetExceededError is returned when the next test case would exceed a cost budget.
+type BudgetExceededError struct {
+	Scope   string
+	MaxCost float64
+	Spent   float64
+	Next    float64
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("%s budget of $%.6f would be exceeded: spent $%.6f, next test case estimated at $%.6f", e.Scope, e.MaxCost, e.Spent, e.Next)
+}
+
+// TestCaseError is a failed repetition of a test case with the step it failed in.
+type TestCaseError struct {
+	Category domain.ErrorCategory
+	Err      error
+}
+
+func (e *TestCaseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TestCaseError) Unwrap() error {
+	return e.Err
+}
+
+func newTestCaseError(category domain.ErrorCategory, format string, args ...interface{}) *TestCaseError {
+	return &TestCaseError{Category: category, Err: fmt.Errorf(format, args...)}
 }
 
 type Report struct {
@@ -20,117 +65,543 @@ type Report struct {
 }
 
 func NewBenchmarkService(benchConfig *domain.BenchmarkConfig) *BenchmarkService {
+	providers := NewProviderFactory(benchConfig)
+	estimator := NewCostEstimator(benchConfig)
 	return &BenchmarkService{
-		cfg: benchConfig,
+		cfg:       benchConfig,
+		providers: providers,
 		metricService: NewMetricService(
 			benchConfig.EvalProvider,
 			benchConfig.EvalModel,
-			benchConfig,
+			providers,
 		),
+		estimator:    estimator,
+		repositories: estimator.repositories,
 	}
 }
 
-func (s *BenchmarkService) RunBenchmark(testSuiteName string) error {
+// EstimateBenchmark projects the cost of running the benchmark without calling any provider.
+func (s *BenchmarkService) EstimateBenchmark(testSuiteName string) (*domain.BenchmarkEstimate, error) {
+	return s.estimator.EstimateBenchmark(testSuiteName)
+}
+
+// RunBenchmark runs the benchmark until it is done, the budget is exhausted, a test case fails with
+// FailFast or ctx is done. The test cases that completed until then are always reported and written
+// to the results, the error that stopped the run is returned after that.
+func (s *BenchmarkService) RunBenchmark(ctx context.Context, testSuiteName string) error {
 	fmt.Printf("Running Benchmark: %s\n", s.cfg.Name)
 	fmt.Printf("- Eval Provider: %s\n", s.cfg.EvalProvider)
 	fmt.Printf("- Eval Model: %s\n", s.cfg.EvalModel)
 	fmt.Printf("----------------------\n")
 
+	if timeout := s.cfg.RunTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	startedAt := time.Now()
+
 	var completedTestSuites []*domain.TestSuite
-	var completedBenchmark *domain.Benchmark
+	status := domain.RunStatusCompleted
+	var stopReason string
+	var runErr error
 	stdOutReport := report.NewStdoutReportCreator()
 
 	for _, testSuiteConfig := range s.cfg.TestSuiteConfigs {
 		if testSuiteName != "" && testSuiteConfig.Name != testSuiteName {
 			continue // Skip if testSuiteName is specified and doesn't match
 		}
-		testSuite, err := s.RunTestSuite(testSuiteConfig)
+		testSuite, err := s.RunTestSuite(ctx, testSuiteConfig)
+		if err != nil && ctx.Err() != nil {
+			// Interrupted or timed out, keep the results of everything that already ran
+			status, stopReason = stopStatus(ctx, s.cfg.RunTimeout())
+			fmt.Printf("Stopping benchmark: %s\n", stopReason)
+			if len(testSuite.TestCases) > 0 {
+				stdOutReport.GenerateTestSuiteReport(testSuite)
+				completedTestSuites = append(completedTestSuites, testSuite)
+			}
+			break
+		}
+		var budgetErr *BudgetExceededError
+		if errors.As(err, &budgetErr) {
+			// Stop cleanly and keep the results of everything that already ran
+			fmt.Printf("Stopping benchmark: %v\n", budgetErr)
+			status = domain.RunStatusBudgetExceeded
+			stopReason = budgetErr.Error()
+			if len(testSuite.TestCases) > 0 {
+				stdOutReport.GenerateTestSuiteReport(testSuite)
+				completedTestSuites = append(completedTestSuites, testSuite)
+			}
+			break
+		}
 		if err != nil {
-			return fmt.Errorf("error running test suite %s: %v", testSuiteConfig.Name, err)
+			// Failed fast, keep the results of everything that already ran
+			runErr = fmt.Errorf("error running test suite %s: %w", testSuiteConfig.Name, err)
+			fmt.Printf("Stopping benchmark: %v\n", runErr)
+			status, stopReason = domain.RunStatusFailed, runErr.Error()
+			if len(testSuite.TestCases) > 0 {
+				stdOutReport.GenerateTestSuiteReport(testSuite)
+				completedTestSuites = append(completedTestSuites, testSuite)
+			}
+			break
 		}
 		stdOutReport.GenerateTestSuiteReport(testSuite)
 		completedTestSuites = append(completedTestSuites, testSuite)
 	}
 
-	completedBenchmark = &domain.Benchmark{
+	err := s.writeReports(&domain.Benchmark{
 		Name:         s.cfg.Name,
+		RunID:        domain.NewRunID(startedAt),
+		BenchmarkDir: s.cfg.Dir,
+		StartedAt:    startedAt,
 		EvalProvider: s.cfg.EvalProvider,
 		EvalModel:    s.cfg.EvalModel,
+		Status:       status,
+		StopReason:   stopReason,
 		TestSuites:   completedTestSuites,
+	})
+	if err != nil {
+		return err
+	}
+	return runErr
+}
+
+// writeReports prints the benchmark report and writes the run to ResultsPath/<run ID>.
+func (s *BenchmarkService) writeReports(benchmark *domain.Benchmark) error {
+	runPath := filepath.Join(s.cfg.ResultsPath, benchmark.RunID)
+	report.NewStdoutReportCreator().GenerateBenchmarkReport(benchmark, benchmark.TestSuites)
+
+	fileReports := []ports.ReportCreator{
+		report.NewJSONReportCreator(runPath),
+		report.NewHTMLReportCreator(runPath),
 	}
-	stdOutReport.GenerateBenchmarkReport(completedBenchmark, completedTestSuites)
+	for _, fileReport := range fileReports {
+		if err := fileReport.GenerateBenchmarkReport(benchmark, benchmark.TestSuites); err != nil {
+			return fmt.Errorf("error generating benchmark report: %v", err)
+		}
+	}
+	fmt.Printf("Results written to: %s\n", runPath)
 
 	return nil
 }
 
-func (s *BenchmarkService) RunTestSuite(cfg domain.TestSuiteConfig) (*domain.TestSuite, error) {
+// testSuiteConfig returns the configuration of the named test suite or nil.
+func (s *BenchmarkService) testSuiteConfig(name string) *domain.TestSuiteConfig {
+	for i := range s.cfg.TestSuiteConfigs {
+		if s.cfg.TestSuiteConfigs[i].Name == name {
+			return &s.cfg.TestSuiteConfigs[i]
+		}
+	}
+	return nil
+}
+
+// stopStatus returns the run status and the reason for a run that was stopped by its context.
+func stopStatus(ctx context.Context, runTimeout time.Duration) (string, string) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return domain.RunStatusTimedOut, fmt.Sprintf("run timeout of %s exceeded", runTimeout)
+	}
+	return domain.RunStatusInterrupted, "interrupted by the user"
+}
+
+func (s *BenchmarkService) RunTestSuite(ctx context.Context, cfg domain.TestSuiteConfig) (*domain.TestSuite, error) {
 	fmt.Printf("Running Test Suite: %s\n", cfg.Name)
 
 	testSuite := &domain.TestSuite{
 		Name:      cfg.Name,
-		TestCases: make([]domain.TestCase, len(cfg.TestCaseConfigs)),
+		Provider:  cfg.Provider,
+		Model:     cfg.Model,
+		TestCases: make([]domain.TestCase, 0, len(cfg.TestCaseConfigs)),
 	}
 
-	for i, testCaseConfig := range cfg.TestCaseConfigs {
-		testCase, err := s.RunTestCase(&cfg, &testCaseConfig)
+	suiteStartCost := s.spent
+	for _, testCaseConfig := range cfg.TestCaseConfigs {
+		testCase, err := s.RunTestCase(ctx, &cfg, &testCaseConfig, suiteStartCost)
+		if len(testCase.Results) > 0 {
+			testSuite.TestCases = append(testSuite.TestCases, testCase)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("error running test case %s: %v", testCaseConfig.Name, err)
+			return testSuite, fmt.Errorf("error running test case %s: %w", testCaseConfig.Name, err)
 		}
-
-		testSuite.TestCases[i] = testCase
 	}
 	fmt.Printf("----------------------\n")
 
 	return testSuite, nil
 }
 
-func (s *BenchmarkService) RunTestCase(testSuiteConfig *domain.TestSuiteConfig, testCaseConfig *domain.TestCaseConfig) (domain.TestCase, error) {
-	fmt.Printf("Running Test Case: %s\n", testCaseConfig.Name)
+// RunTestCase runs all repetitions of a test case. suiteStartCost is the cost of the run when the
+// test suite started, it is used to enforce the test suite budget. A failed repetition is recorded
+// with its error and the next one runs, unless FailFast is set. On error the repetitions that
+// completed are still returned.
+func (s *BenchmarkService) RunTestCase(ctx context.Context, testSuiteConfig *domain.TestSuiteConfig, testCaseConfig *domain.TestCaseConfig, suiteStartCost float64) (domain.TestCase, error) {
+	repetitions := resolveRepetitions(s.cfg, testSuiteConfig, testCaseConfig)
 
-	llmService, err := NewLLMService(
-		testSuiteConfig.Provider,
-		testSuiteConfig.Model,
-		s.cfg,
-	)
+	testCase := domain.TestCase{
+		Name:          testCaseConfig.Name,
+		Input:         testCaseConfig.Input,
+		Expected:      testCaseConfig.Expected,
+		Tags:          testCaseConfig.Tags,
+		Conversation:  testCaseConfig.Conversation,
+		PassThreshold: testSuiteConfig.PassThreshold,
+		Results:       make([]*domain.TestResult, 0, repetitions),
+	}
+	if testCaseConfig.Tools != nil {
+		testCase.ExpectedToolCalls = testCaseConfig.Tools.ExpectedCalls
+	}
+
+	for repetition := 1; repetition <= repetitions; repetition++ {
+		if err := ctx.Err(); err != nil {
+			return testCase, err
+		}
+		if repetitions > 1 {
+			fmt.Printf("Running Test Case: %s (repetition %d/%d)\n", testCaseConfig.Name, repetition, repetitions)
+		} else {
+			fmt.Printf("Running Test Case: %s\n", testCaseConfig.Name)
+		}
+
+		// A repetition whose cost cannot be estimated fails like one whose input cannot be read
+		result := &domain.TestResult{}
+		err := s.checkBudget(testSuiteConfig, testCaseConfig, suiteStartCost)
+		if err == nil {
+			result, err = s.runRepetition(llm.WithCacheSample(ctx, repetition), testSuiteConfig, testCaseConfig)
+		}
+		if err != nil {
+			var testCaseErr *TestCaseError
+			if s.cfg.FailFast || ctx.Err() != nil || !errors.As(err, &testCaseErr) {
+				return testCase, err
+			}
+			fmt.Printf("Test case %s failed (%s): %v\n", testCaseConfig.Name, testCaseErr.Category, err)
+			result.Error = &domain.TestError{Category: testCaseErr.Category, Message: err.Error()}
+		}
+		result.Repetition = repetition
+		testCase.Results = append(testCase.Results, result)
+		s.spent += result.TotalCost()
+	}
+
+	return testCase, nil
+}
+
+// checkBudget returns a BudgetExceededError if the estimated cost of the next repetition would
+// exceed the benchmark or the test suite budget, and an input TestCaseError if it cannot be estimated.
+func (s *BenchmarkService) checkBudget(testSuiteConfig *domain.TestSuiteConfig, testCaseConfig *domain.TestCaseConfig, suiteStartCost float64) error {
+	if s.cfg.MaxCost <= 0 && testSuiteConfig.MaxCost <= 0 {
+		return nil
+	}
+
+	estimate, err := s.estimator.EstimateTestCase(testSuiteConfig, testCaseConfig)
 	if err != nil {
-		return domain.TestCase{}, fmt.Errorf("error creating LLM service: %v", err)
+		return newTestCaseError(domain.ErrorCategoryInput, "error estimating test case cost: %v", err)
 	}
+	return s.checkEstimate(testSuiteConfig, estimate, suiteStartCost)
+}
 
-	startTime := time.Now()
+// checkEstimate returns a BudgetExceededError if the estimated cost of the next calls would exceed
+// the benchmark or the test suite budget.
+func (s *BenchmarkService) checkEstimate(testSuiteConfig *domain.TestSuiteConfig, estimate domain.CostEstimate, suiteStartCost float64) error {
+	next := estimate.TotalCost()
+	if estimate.UnknownCost && !s.unpricedSuites[testSuiteConfig.Name] {
+		if s.unpricedSuites == nil {
+			s.unpricedSuites = map[string]bool{}
+		}
+		s.unpricedSuites[testSuiteConfig.Name] = true
+		fmt.Printf("Warning: the price of a model of test suite %s is unknown, its calls count as $0 and the budget does not limit them\n", testSuiteConfig.Name)
+	}
+
+	if s.cfg.MaxCost > 0 && s.spent+next > s.cfg.MaxCost {
+		return &BudgetExceededError{Scope: "benchmark", MaxCost: s.cfg.MaxCost, Spent: s.spent, Next: next}
+	}
+	suiteSpent := s.spent - suiteStartCost
+	if testSuiteConfig.MaxCost > 0 && suiteSpent+next > testSuiteConfig.MaxCost {
+		return &BudgetExceededError{Scope: "test suite " + testSuiteConfig.Name, MaxCost: testSuiteConfig.MaxCost, Spent: suiteSpent, Next: next}
+	}
+	return nil
+}
+
+// resolveRepetitions resolves how often a test case is run: the --repeat flag wins over the test case
+// and test suite configuration, the default is a single run.
+func resolveRepetitions(cfg *domain.BenchmarkConfig, testSuiteConfig *domain.TestSuiteConfig, testCaseConfig *domain.TestCaseConfig) int {
+	switch {
+	case cfg.Repetitions > 0:
+		return cfg.Repetitions
+	case testCaseConfig.Repetitions > 0:
+		return testCaseConfig.Repetitions
+	case testSuiteConfig.Repetitions > 0:
+		return testSuiteConfig.Repetitions
+	default:
+		return 1
+	}
+}
+
+// absCaseFiles converts the paths of files that are relative to the test case to absolute paths and
+// checks that the files exist.
+func absCaseFiles(testCaseConfig *domain.TestCaseConfig, paths []string) ([]string, error) {
+	absPaths := make([]string, len(paths))
+	for i, path := range paths {
+		absPath, err := filepath.Abs(filepath.Join(testCaseConfig.Path, path))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(absPath); err != nil {
+			return nil, err
+		}
+		absPaths[i] = absPath
+	}
+	return absPaths, nil
+}
+
+// diagramInputs returns the diagram files as images or, in source mode, as documents. In image mode
+// the diagrams are rendered with the render command of the test suite.
+func diagramInputs(ctx context.Context, cfg *domain.BenchmarkConfig, testSuiteConfig *domain.TestSuiteConfig, paths []string) ([]string, []string, error) {
+	if testSuiteConfig.Diagrams.Mode != domain.DiagramModeImage {
+		return nil, paths, nil
+	}
+
+	images := make([]string, len(paths))
+	for i, path := range paths {
+		kind, err := domain.DiagramKindOf(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		command := testSuiteConfig.Diagrams.Renderer(kind)
+		if command == "" {
+			return nil, nil, fmt.Errorf("no renderer configured for %s diagrams", kind)
+		}
+		if images[i], err = diagram.Render(ctx, path, command, testSuiteConfig.Path, cfg.DiagramsPath); err != nil {
+			return nil, nil, err
+		}
+	}
+	return images, nil, nil
+}
+
+// repositoryPacks packs the repository of a test case once per run, its repetitions and its cost
+// estimate share the packed document.
+type repositoryPacks struct {
+	mu     sync.Mutex
+	packed map[string]domain.Document
+}
+
+func newRepositoryPacks() *repositoryPacks {
+	return &repositoryPacks{packed: make(map[string]domain.Document)}
+}
+
+// pack returns the packed repository of the test case, packing it on first use.
+func (p *repositoryPacks) pack(testCaseConfig *domain.TestCaseConfig) (domain.Document, error) {
+	// Test cases of a dataset share their directory, but not their name
+	key := testCaseConfig.Path + "\x00" + testCaseConfig.Name
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if document, ok := p.packed[key]; ok {
+		return document, nil
+	}
+	document, err := packRepository(testCaseConfig)
+	if err != nil {
+		return domain.Document{}, err
+	}
+	p.packed[key] = document
+	return document, nil
+}
+
+// packRepository packs the source tree of a test case into a text document for the prompt.
+func packRepository(testCaseConfig *domain.TestCaseConfig) (domain.Document, error) {
+	config := testCaseConfig.Repository
+	source, err := repository.Open(filepath.Join(testCaseConfig.Path, config.Path))
+	if err != nil {
+		return domain.Document{}, err
+	}
+	maxTokens := config.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = domain.DefaultRepositoryTokens
+	}
+	packed, err := source.Pack(repository.PackOptions{
+		Include:        config.Include,
+		Exclude:        config.Exclude,
+		MaxTokens:      maxTokens,
+		EstimateTokens: llm.EstimateTextTokens,
+	})
+	if err != nil {
+		return domain.Document{}, err
+	}
+	return domain.Document{Name: config.Path, MimeType: "text/plain", Text: packed.Text}, nil
+}
+
+// runRepetition runs the test case once. On error it returns a TestCaseError together with the
+// result so far, which holds the output and the calls that were made before the failure.
+func (s *BenchmarkService) runRepetition(ctx context.Context, testSuiteConfig *domain.TestSuiteConfig, testCaseConfig *domain.TestCaseConfig) (*domain.TestResult, error) {
+	result := &domain.TestResult{}
+	ctx = llm.WithMockConfig(ctx, testCaseConfig.Mock)
 
-	// Convert relative image paths to absolute paths
-	absImages := make([]string, len(testCaseConfig.Images))
-	for i, imagePath := range testCaseConfig.Images {
-		absPath, err := filepath.Abs(filepath.Join(testCaseConfig.Path, imagePath))
+	options := []LLMServiceOption{WithImageConfig(testSuiteConfig.ImageProcessing)}
+	if testCaseConfig.Repository != nil {
+		repositoryDocument, err := s.repositories.pack(testCaseConfig)
 		if err != nil {
-			return domain.TestCase{}, fmt.Errorf("error converting image path to absolute path: %w", err)
+			return result, newTestCaseError(domain.ErrorCategoryInput, "error packing repository: %w", err)
 		}
-		absImages[i] = absPath
+		options = append(options, WithDocuments(repositoryDocument))
+	}
+	llmService, err := NewLLMService(s.providers, testSuiteConfig.Provider, testSuiteConfig.Model, options...)
+	if err != nil {
+		return result, newTestCaseError(domain.ErrorCategoryConfiguration, "error creating LLM service: %v", err)
+	}
+
+	absImages, err := absCaseFiles(testCaseConfig, testCaseConfig.Images)
+	if err != nil {
+		return result, newTestCaseError(domain.ErrorCategoryInput, "error reading image: %w", err)
+	}
+	absDocuments, err := absCaseFiles(testCaseConfig, testCaseConfig.Documents)
+	if err != nil {
+		return result, newTestCaseError(domain.ErrorCategoryInput, "error reading document: %w", err)
+	}
+	absDiagrams, err := absCaseFiles(testCaseConfig, testCaseConfig.Diagrams)
+	if err != nil {
+		return result, newTestCaseError(domain.ErrorCategoryInput, "error reading diagram: %w", err)
+	}
+	diagramImages, diagramDocuments, err := diagramInputs(ctx, s.cfg, testSuiteConfig, absDiagrams)
+	if err != nil {
+		return result, newTestCaseError(domain.ErrorCategoryInput, "error preparing diagram: %w", err)
+	}
+	absImages = append(absImages, diagramImages...)
+	absDocuments = append(absDocuments, diagramDocuments...)
+
+	generation := testSuiteConfig.Generation.Merge(testCaseConfig.Generation)
+	if len(testCaseConfig.Conversation) > 0 {
+		return result, s.runConversation(ctx, llmService, testSuiteConfig, testCaseConfig, result, absImages, absDocuments, generation)
+	}
+
+	if testCaseConfig.Tools != nil {
+		return result, s.runWithTools(ctx, llmService, testSuiteConfig, testCaseConfig, result, absImages, absDocuments, generation)
+	}
+
+	startTime := time.Now()
+	llmResponse, generationCall, err := llmService.GenerateResponse(ctx, domain.CallRoleGeneration, "", nil, testCaseConfig.Input, absImages, absDocuments, generation)
+	result.Duration = time.Since(startTime)
+	if err != nil {
+		return result, newTestCaseError(domain.ErrorCategoryProvider, "error creating LLM response: %w", err)
 	}
+	result.Output = llmResponse.Response
+	result.Generation = llmResponse.Generation
+	result.AddCall(generationCall)
 
-	llmResponse, err := llmService.GenerateResponse(testCaseConfig.Input, testCaseConfig.Expected, absImages)
+	metrics, evaluationCalls, err := s.metricService.CalculateMetrics(
+		ctx,
+		testSuiteConfig.CaseMetrics(testCaseConfig),
+		llmResponse.Response,
+		testCaseConfig.Expected,
+		domain.Trajectory{},
+	)
+	for _, call := range evaluationCalls {
+		result.AddCall(call)
+	}
 	if err != nil {
-		return domain.TestCase{}, fmt.Errorf("error creating LLM response: %v", err)
+		return result, newTestCaseError(domain.ErrorCategoryEvaluation, "error calculating metrics: %w", err)
 	}
-	duration := time.Since(startTime)
+	result.Metrics = metrics
+
+	return result, nil
+}
 
-	metrics, err := s.metricService.CalculateMetrics(
+// runWithTools runs a test case with tools: the model can call the read-only tools on the fixture
+// before it answers. The answer is scored together with the trajectory of the tool calls.
+func (s *BenchmarkService) runWithTools(ctx context.Context, llmService *LLMService, testSuiteConfig *domain.TestSuiteConfig, testCaseConfig *domain.TestCaseConfig, result *domain.TestResult, images, documents []string, generation domain.GenerationConfig) error {
+	toolbox, err := newToolbox(testCaseConfig)
+	if err != nil {
+		return newTestCaseError(domain.ErrorCategoryInput, "error opening the fixture: %w", err)
+	}
+	request, err := llmService.NewRequest("", nil, testCaseConfig.Input, images, documents, generation)
+	if err != nil {
+		return newTestCaseError(domain.ErrorCategoryInput, "error creating LLM request: %w", err)
+	}
+
+	startTime := time.Now()
+	llmResponse, err := runToolLoop(ctx, llmService, toolbox, testCaseConfig.Tools.MaxCalls, request, result)
+	result.Duration = time.Since(startTime)
+	if errors.Is(err, errMaxToolCalls) {
+		return newTestCaseError(domain.ErrorCategoryModel, "error running the tool loop: %w", err)
+	}
+	if err != nil {
+		return newTestCaseError(domain.ErrorCategoryProvider, "error creating LLM response: %w", err)
+	}
+	result.Output = llmResponse.Response
+	result.Generation = llmResponse.Generation
+
+	metrics, evaluationCalls, err := s.metricService.CalculateMetrics(
+		ctx,
+		testSuiteConfig.CaseMetrics(testCaseConfig),
 		llmResponse.Response,
 		testCaseConfig.Expected,
+		domain.Trajectory{Calls: result.ToolCalls, Expected: testCaseConfig.Tools.ExpectedCalls},
 	)
+	for _, call := range evaluationCalls {
+		result.AddCall(call)
+	}
 	if err != nil {
-		return domain.TestCase{}, fmt.Errorf("error calculating metrics: %v", err)
+		return newTestCaseError(domain.ErrorCategoryEvaluation, "error calculating metrics: %w", err)
 	}
+	result.Metrics = metrics
+	return nil
+}
+
+// runConversation plays the scripted conversation of a test case. The model answers every user turn
+// that is not followed by a scripted assistant turn, the images and documents are attached to every
+// answered user message. The final answer is scored against the expected answer of its turn or the
+// expected output of the test case, with ScoreTurnsAll every other answer with an expected answer too.
+func (s *BenchmarkService) runConversation(ctx context.Context, llmService *LLMService, testSuiteConfig *domain.TestSuiteConfig, testCaseConfig *domain.TestCaseConfig, result *domain.TestResult, images, documents []string, generation domain.GenerationConfig) error {
+	conversation := testCaseConfig.Conversation
+	var history []domain.Message
+	for i, turn := range conversation {
+		if turn.Role == domain.RoleAssistant || (i+1 < len(conversation) && conversation[i+1].Role == domain.RoleAssistant) {
+			// Scripted turns, the user turn is answered by the next turn
+			history = append(history, domain.Message{Role: turn.Role, Content: turn.Content})
+			continue
+		}
+
+		startTime := time.Now()
+		llmResponse, generationCall, err := llmService.GenerateResponse(ctx, domain.CallRoleGeneration, "", history, turn.Content, images, documents, generation)
+		result.Duration += time.Since(startTime)
+		if err != nil {
+			return newTestCaseError(domain.ErrorCategoryProvider, "error creating LLM response to turn %d: %w", i+1, err)
+		}
+		result.AddCall(generationCall)
+		result.Output = llmResponse.Response
+		result.Generation = llmResponse.Generation
 
-	return domain.TestCase{
-		Name:     testCaseConfig.Name,
-		Input:    testCaseConfig.Input,
-		Expected: testCaseConfig.Expected,
-		Result: &domain.TestResult{
-			Output:   llmResponse.Response,
-			Metrics:  metrics,
-			Duration: duration,
-			Cost:     llmResponse.Cost,
-		},
-	}, nil
+		turnResult := domain.TurnResult{Turn: i + 1, Input: turn.Content, Output: llmResponse.Response}
+		if testCaseConfig.ScoreTurns == domain.ScoreTurnsAll {
+			turnResult.Expected = turn.Expected
+		}
+		if i == len(conversation)-1 {
+			turnResult.Expected = turn.Expected
+			if turnResult.Expected == "" {
+				turnResult.Expected = testCaseConfig.Expected
+			}
+		}
+		result.Turns = append(result.Turns, turnResult)
+		history = append(history,
+			domain.Message{Role: domain.RoleUser, Content: turn.Content},
+			domain.Message{Role: domain.RoleAssistant, Content: llmResponse.Response},
+		)
+	}
+
+	return s.scoreTurns(ctx, testSuiteConfig.CaseMetrics(testCaseConfig), result)
+}
+
+// scoreTurns scores the answers of a conversation that have an expected answer. The metrics of the
+// result are the averages over the scored answers.
+func (s *BenchmarkService) scoreTurns(ctx context.Context, metricConfigs []domain.MetricConfig, result *domain.TestResult) error {
+	for i := range result.Turns {
+		turn := &result.Turns[i]
+		if turn.Expected == "" {
+			continue
+		}
+		metrics, evaluationCalls, err := s.metricService.CalculateMetrics(ctx, metricConfigs, turn.Output, turn.Expected, domain.Trajectory{})
+		for _, call := range evaluationCalls {
+			result.AddCall(call)
+		}
+		if err != nil {
+			return newTestCaseError(domain.ErrorCategoryEvaluation, "error calculating metrics of turn %d: %w", turn.Turn, err)
+		}
+		turn.Metrics = metrics
+	}
+	result.Metrics = domain.AverageTurnMetrics(result.Turns)
+	return nil
 }
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/config"
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/report"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+// loadBenchmark loads a benchmark of the repository with the given --set flags. The settings of
+// the host, its environment, .env and global config file, are left out. The results and the cache
+// go to temporary directories.
+func loadBenchmark(t *testing.T, name string, setFlags ...string) *domain.BenchmarkConfig {
+	t.Helper()
+	for _, envVar := range []string{"EVAL_PROVIDER", "EVAL_MODEL", "EVAL_API_KEY", "ARCH_BENCH_MAX_COST", "ARCH_BENCH_CALL_TIMEOUT_SECONDS", "ARCH_BENCH_RUN_TIMEOUT_SECONDS", "ARCH_BENCH_CACHE"} {
+		t.Setenv(envVar, "")
+		os.Unsetenv(envVar)
+	}
+	// The .env file and the global config file are looked up in the home and user config directories
+	t.Setenv(config.HomeEnv, t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	cfg, err := config.LoadConfig(filepath.Join("..", "..", "..", config.BenchmarksDirName), "", setFlags)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	loader, err := config.NewBenchmarkConfigLoader(cfg.Paths, name)
+	if err != nil {
+		t.Fatalf("benchmark config loader: %v", err)
+	}
+	benchConfig, err := loader.LoadBenchmarkConfig(cfg)
+	if err != nil {
+		t.Fatalf("load benchmark config: %v", err)
+	}
+	benchConfig.ResultsPath = t.TempDir()
+	benchConfig.CachePath = t.TempDir()
+	benchConfig.DiagramsPath = t.TempDir()
+	return benchConfig
+}
+
+// runBenchmark runs the benchmark and returns its report.
+func runBenchmark(t *testing.T, benchConfig *domain.BenchmarkConfig) *domain.Benchmark {
+	t.Helper()
+	if err := NewBenchmarkService(benchConfig).RunBenchmark(context.Background(), ""); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	reports, err := filepath.Glob(filepath.Join(benchConfig.ResultsPath, "*", "report.json"))
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("got reports %v (%v), want one", reports, err)
+	}
+	benchmark, err := report.LoadBenchmarkReport(reports[0])
+	if err != nil {
+		t.Fatalf("load report: %v", err)
+	}
+	return benchmark
+}
+
+// TestRunDemoFromFixtures replays the demo from its synthetic fixtures. It checks that a run replays
+// offline, the made up answers and costs are not asserted.
+func TestRunDemoFromFixtures(t *testing.T) {
+	benchConfig := loadBenchmark(t, "demo", "eval_provider=openai", "eval_model=gpt-4o-mini", "cache=off")
+	benchConfig.ReplayMode = domain.ReplayModeReplay
+
+	benchmark := runBenchmark(t, benchConfig)
+	if benchmark.Status != domain.RunStatusCompleted || len(benchmark.TestSuites) != 2 {
+		t.Fatalf("status %s with %d test suites, want %s with 2", benchmark.Status, len(benchmark.TestSuites), domain.RunStatusCompleted)
+	}
+	// rescore loads the benchmark of the run from its report
+	if wantDir, _ := filepath.Abs(filepath.Join("..", "..", "..", config.BenchmarksDirName, "demo")); benchmark.BenchmarkDir != wantDir {
+		t.Errorf("benchmark dir = %q, want %q", benchmark.BenchmarkDir, wantDir)
+	}
+	for _, testSuite := range benchmark.TestSuites {
+		for _, testCase := range testSuite.TestCases {
+			if testCase.Errors() != 0 {
+				t.Errorf("%s/%s failed: %+v", testSuite.Name, testCase.Name, testCase.Results[0].Error)
+			}
+			if testCase.CalculateAverageRating() <= 0 {
+				t.Errorf("%s/%s has no rating", testSuite.Name, testCase.Name)
+			}
+		}
+	}
+}
+
+func TestRunMockBenchmark(t *testing.T) {
+	benchConfig := loadBenchmark(t, "mock", "eval_provider=mock", "eval_model=mock-judge", "cache=off")
+
+	benchmark := runBenchmark(t, benchConfig)
+	tests := []struct {
+		testSuite    string
+		testCase     string
+		wantCategory domain.ErrorCategory
+		wantRating   float64
+	}{
+		{testSuite: "errors", testCase: "bad_request", wantCategory: domain.ErrorCategoryProvider},
+		{testSuite: "errors", testCase: "timeout", wantCategory: domain.ErrorCategoryProvider},
+		{testSuite: "errors", testCase: "malformed_judge", wantCategory: domain.ErrorCategoryEvaluation},
+		// geval 80 and relevance 50, the rate limit is retried
+		{testSuite: "errors", testCase: "rate_limit_recovers", wantRating: 65},
+		{testSuite: "scores", testCase: "high_score", wantRating: 90},
+		{testSuite: "scores", testCase: "low_score", wantRating: 20},
+		{testSuite: "tools", testCase: "max_calls", wantCategory: domain.ErrorCategoryModel},
+		// geval 80 and tool_trajectory 100
+		{testSuite: "tools", testCase: "agent", wantRating: 90},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testSuite+"/"+tt.testCase, func(t *testing.T) {
+			testSuite := benchmark.TestSuite(tt.testSuite)
+			if testSuite == nil || testSuite.TestCase(tt.testCase) == nil {
+				t.Fatalf("test case missing in the report")
+			}
+			result := testSuite.TestCase(tt.testCase).Results[0]
+			if tt.wantCategory != "" {
+				if result.Error == nil || result.Error.Category != tt.wantCategory {
+					t.Errorf("error = %+v, want category %s", result.Error, tt.wantCategory)
+				}
+				return
+			}
+			if result.Failed() {
+				t.Fatalf("failed: %+v", result.Error)
+			}
+			if rating := testSuite.TestCase(tt.testCase).CalculateAverageRating(); rating != tt.wantRating {
+				t.Errorf("rating = %v, want %v", rating, tt.wantRating)
+			}
+		})
+	}
+
+	t.Run("tools/agent trajectory", func(t *testing.T) {
+		result := benchmark.TestSuite("tools").TestCase("agent").Results[0]
+		var calls []string
+		for _, call := range result.ToolCalls {
+			calls = append(calls, call.Name+" "+call.Arguments)
+		}
+		want := []string{"list_modules {}", `dependencies {"module":"internal/core"}`, `read_file {"path":"../../config.json"}`, `read_file {"path":"go.mod"}`}
+		if !slices.Equal(calls, want) {
+			t.Fatalf("tool calls = %q, want %q", calls, want)
+		}
+		// The read outside of the fixture fails, the model gets the error and goes on
+		if result.ToolCalls[2].Error == "" || result.ToolCalls[3].Error != "" {
+			t.Errorf("tool call errors = %q, %q, want only the read outside of the fixture to fail", result.ToolCalls[2].Error, result.ToolCalls[3].Error)
+		}
+	})
+}
+
+// TestRunBudgetWithMissingImage checks that a test case whose cost cannot be estimated fails on its
+// own, and that a run stopped by it with FailFast still writes the results so far.
+func TestRunBudgetWithMissingImage(t *testing.T) {
+	tests := []struct {
+		name       string
+		failFast   bool
+		wantStatus string
+		wantCases  int
+	}{
+		{name: "continue", wantStatus: domain.RunStatusCompleted, wantCases: 2},
+		{name: "fail fast", failFast: true, wantStatus: domain.RunStatusFailed, wantCases: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			benchConfig := loadBenchmark(t, "mock", "eval_provider=mock", "eval_model=mock-judge", "cache=off", "max_cost=100")
+			benchConfig.FailFast = tt.failFast
+			for i := range benchConfig.TestSuiteConfigs {
+				testSuiteConfig := &benchConfig.TestSuiteConfigs[i]
+				for j := range testSuiteConfig.TestCaseConfigs {
+					if testSuiteConfig.TestCaseConfigs[j].Name == "low_score" {
+						testSuiteConfig.TestCaseConfigs[j].Images = []string{"missing.png"}
+					}
+				}
+			}
+
+			err := NewBenchmarkService(benchConfig).RunBenchmark(context.Background(), "scores")
+			if (err != nil) != tt.failFast {
+				t.Fatalf("run: %v", err)
+			}
+			reports, err := filepath.Glob(filepath.Join(benchConfig.ResultsPath, "*", "report.json"))
+			if err != nil || len(reports) != 1 {
+				t.Fatalf("got reports %v (%v), want one", reports, err)
+			}
+			benchmark, err := report.LoadBenchmarkReport(reports[0])
+			if err != nil {
+				t.Fatalf("load report: %v", err)
+			}
+			if benchmark.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", benchmark.Status, tt.wantStatus)
+			}
+			testSuite := benchmark.TestSuite("scores")
+			if testSuite == nil || len(testSuite.TestCases) != tt.wantCases {
+				t.Fatalf("report = %+v, want %d test cases", testSuite, tt.wantCases)
+			}
+			if highScore := testSuite.TestCase("high_score"); highScore == nil || highScore.Errors() != 0 {
+				t.Errorf("high_score = %+v, want it to pass", highScore)
+			}
+			if tt.failFast {
+				return
+			}
+			if result := testSuite.TestCase("low_score").Results[0]; result.Error == nil || result.Error.Category != domain.ErrorCategoryInput {
+				t.Errorf("low_score error = %+v, want category %s", result.Error, domain.ErrorCategoryInput)
+			}
+		})
+	}
+}
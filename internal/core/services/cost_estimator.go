@@ -0,0 +1,325 @@
+package services
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/diagram"
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/document"
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/imaging"
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/llm"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+const (
+	// Rough sizes of the evaluation responses, used when projecting the cost of the G-Eval calls
+	estimatedChainOfThoughtsTokens = 300
+	estimatedJudgeResponseTokens   = 10
+	// Rough sizes of the tool definitions and of a tool result, used for test cases with tools
+	estimatedToolDefinitionTokens = 400
+	estimatedToolResultTokens     = 1000
+)
+
+// CostEstimator projects the token usage and cost of a benchmark run without calling any provider.
+type CostEstimator struct {
+	cfg          *domain.BenchmarkConfig
+	repositories *repositoryPacks
+}
+
+func NewCostEstimator(cfg *domain.BenchmarkConfig) *CostEstimator {
+	return &CostEstimator{cfg: cfg, repositories: newRepositoryPacks()}
+}
+
+func (e *CostEstimator) EstimateBenchmark(testSuiteName string) (*domain.BenchmarkEstimate, error) {
+	estimate := &domain.BenchmarkEstimate{
+		Name:      e.cfg.Name,
+		EvalModel: e.cfg.EvalModel,
+		MaxCost:   e.cfg.MaxCost,
+	}
+
+	for i := range e.cfg.TestSuiteConfigs {
+		testSuiteConfig := &e.cfg.TestSuiteConfigs[i]
+		if testSuiteName != "" && testSuiteConfig.Name != testSuiteName {
+			continue
+		}
+
+		suiteEstimate := domain.TestSuiteEstimate{
+			Name:      testSuiteConfig.Name,
+			Provider:  testSuiteConfig.Provider,
+			Model:     testSuiteConfig.Model,
+			TestCases: len(testSuiteConfig.TestCaseConfigs),
+			MaxCost:   testSuiteConfig.MaxCost,
+		}
+		for j := range testSuiteConfig.TestCaseConfigs {
+			testCaseConfig := &testSuiteConfig.TestCaseConfigs[j]
+			caseEstimate, err := e.EstimateTestCase(testSuiteConfig, testCaseConfig)
+			if err != nil {
+				return nil, fmt.Errorf("error estimating test case %s: %v", testCaseConfig.Name, err)
+			}
+			repetitions := resolveRepetitions(e.cfg, testSuiteConfig, testCaseConfig)
+			suiteEstimate.Estimate = suiteEstimate.Estimate.Add(caseEstimate.Scale(repetitions))
+		}
+
+		estimate.TestSuites = append(estimate.TestSuites, suiteEstimate)
+		estimate.Total = estimate.Total.Add(suiteEstimate.Estimate)
+	}
+
+	return estimate, nil
+}
+
+// EstimateTestCase projects a single repetition of a test case: the generation calls and the
+// chain of thoughts and judge calls of every geval metric.
+func (e *CostEstimator) EstimateTestCase(testSuiteConfig *domain.TestSuiteConfig, testCaseConfig *domain.TestCaseConfig) (domain.CostEstimate, error) {
+	attachmentTokens := 0
+	imageOptions := imaging.NewOptions(testSuiteConfig.ImageProcessing, llm.ImageLimits(testSuiteConfig.Provider))
+	for _, imagePath := range testCaseConfig.Images {
+		imageTokens, err := estimateImageTokens(filepath.Join(testCaseConfig.Path, imagePath), imageOptions)
+		if err != nil {
+			return domain.CostEstimate{}, err
+		}
+		attachmentTokens += imageTokens
+	}
+	documentPaths := make([]string, len(testCaseConfig.Documents))
+	for i, documentPath := range testCaseConfig.Documents {
+		documentPaths[i] = filepath.Join(testCaseConfig.Path, documentPath)
+	}
+	diagramPaths := make([]string, len(testCaseConfig.Diagrams))
+	for i, diagramPath := range testCaseConfig.Diagrams {
+		diagramPaths[i] = filepath.Join(testCaseConfig.Path, diagramPath)
+	}
+	var diagramDocuments []string
+	if testSuiteConfig.Diagrams.Mode == domain.DiagramModeImage {
+		diagramTokens, err := e.estimateDiagramTokens(testSuiteConfig, diagramPaths, imageOptions)
+		if err != nil {
+			return domain.CostEstimate{}, err
+		}
+		attachmentTokens += diagramTokens
+	} else {
+		diagramDocuments = diagramPaths
+	}
+	nativePDF := llm.SupportsNativePDF(testSuiteConfig.Provider, testSuiteConfig.Model)
+	for _, documentPath := range append(documentPaths, diagramDocuments...) {
+		// A PDF that is sent as file is approximated by its text, the page images are not counted
+		loadedDocument, err := document.Load(documentPath, nativePDF)
+		if err != nil {
+			return domain.CostEstimate{}, fmt.Errorf("error loading document %s: %w", filepath.Base(documentPath), err)
+		}
+		attachmentTokens += llm.EstimateTextTokens(loadedDocument.Text)
+	}
+	if testCaseConfig.Repository != nil {
+		repositoryDocument, err := e.repositories.pack(testCaseConfig)
+		if err != nil {
+			return domain.CostEstimate{}, fmt.Errorf("error packing repository: %w", err)
+		}
+		attachmentTokens += llm.EstimateTextTokens(repositoryDocument.Text)
+	}
+
+	generation := testSuiteConfig.Generation.Merge(testCaseConfig.Generation)
+	if len(testCaseConfig.Conversation) > 0 {
+		return e.estimateConversation(testSuiteConfig, testCaseConfig, attachmentTokens, generation), nil
+	}
+
+	promptTokens := llm.EstimateTextTokens(testCaseConfig.Input) + attachmentTokens
+	completionTokens := estimateCompletionTokens(testCaseConfig.Expected, generation)
+	estimate := e.estimateGeneration(testSuiteConfig, promptTokens, completionTokens)
+	if testCaseConfig.Tools != nil {
+		// A call per expected tool call, each with the results so far, before the answer
+		estimate = e.estimateGeneration(testSuiteConfig, promptTokens+estimatedToolDefinitionTokens, completionTokens)
+		for i := range testCaseConfig.Tools.ExpectedCalls {
+			estimate = estimate.Add(e.estimateGeneration(testSuiteConfig, promptTokens+estimatedToolDefinitionTokens+(i+1)*estimatedToolResultTokens, completionTokens))
+		}
+	}
+	for _, metricConfig := range testSuiteConfig.CaseMetrics(testCaseConfig) {
+		if metricConfig.Name == "geval" {
+			estimate = estimate.Add(e.estimateGEval(metricConfig, testCaseConfig.Expected, completionTokens))
+		}
+	}
+
+	return estimate, nil
+}
+
+// EstimateEvaluation projects scoring a stored result again: the geval calls for its output, or for
+// every scored answer of a conversation.
+func (e *CostEstimator) EstimateEvaluation(metricConfigs []domain.MetricConfig, expected string, result *domain.TestResult) domain.CostEstimate {
+	type answer struct {
+		expected, output string
+	}
+	answers := []answer{{expected: expected, output: result.Output}}
+	if len(result.Turns) > 0 {
+		answers = nil
+		for _, turn := range result.Turns {
+			if turn.Expected != "" {
+				answers = append(answers, answer{expected: turn.Expected, output: turn.Output})
+			}
+		}
+	}
+
+	estimate := domain.CostEstimate{}
+	for _, answer := range answers {
+		for _, metricConfig := range metricConfigs {
+			if metricConfig.Name == "geval" {
+				estimate = estimate.Add(e.estimateGEval(metricConfig, answer.expected, llm.EstimateTextTokens(answer.output)))
+			}
+		}
+	}
+	return estimate
+}
+
+// estimateConversation projects a conversation: a generation call for every answered user turn
+// with the previous turns as context, and the geval calls for every scored answer.
+func (e *CostEstimator) estimateConversation(testSuiteConfig *domain.TestSuiteConfig, testCaseConfig *domain.TestCaseConfig, attachmentTokens int, generation domain.GenerationConfig) domain.CostEstimate {
+	estimate := domain.CostEstimate{}
+	conversation := testCaseConfig.Conversation
+	contextTokens := 0
+	for i, turn := range conversation {
+		turnTokens := llm.EstimateTextTokens(turn.Content)
+		if turn.Role == domain.RoleAssistant || (i+1 < len(conversation) && conversation[i+1].Role == domain.RoleAssistant) {
+			contextTokens += turnTokens
+			continue
+		}
+
+		final := i == len(conversation)-1
+		expected := turn.Expected
+		if expected == "" && final {
+			expected = testCaseConfig.Expected
+		}
+		// Answers to turns without an expected answer are guessed to be as long as the expected output
+		completionTokens := estimateCompletionTokens(cmp.Or(expected, testCaseConfig.Expected), generation)
+		estimate = estimate.Add(e.estimateGeneration(testSuiteConfig, contextTokens+turnTokens+attachmentTokens, completionTokens))
+		contextTokens += turnTokens + completionTokens
+
+		if expected == "" || (!final && testCaseConfig.ScoreTurns != domain.ScoreTurnsAll) {
+			continue
+		}
+		for _, metricConfig := range testSuiteConfig.CaseMetrics(testCaseConfig) {
+			if metricConfig.Name == "geval" {
+				estimate = estimate.Add(e.estimateGEval(metricConfig, expected, completionTokens))
+			}
+		}
+	}
+	return estimate
+}
+
+// estimateGeneration projects a generation call of the test suite.
+func (e *CostEstimator) estimateGeneration(testSuiteConfig *domain.TestSuiteConfig, promptTokens, completionTokens int) domain.CostEstimate {
+	generationCost, known := e.estimateCost(testSuiteConfig.Provider, testSuiteConfig.Model, promptTokens, completionTokens)
+	return domain.CostEstimate{
+		Calls:            1,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		GenerationCost:   generationCost,
+		UnknownCost:      !known,
+	}
+}
+
+// estimateCompletionTokens takes the expected output as the best guess for the length of the
+// response, capped by the max tokens of the generation.
+func estimateCompletionTokens(expected string, generation domain.GenerationConfig) int {
+	completionTokens := llm.EstimateTextTokens(expected)
+	if generation.MaxTokens != nil && *generation.MaxTokens < completionTokens {
+		completionTokens = *generation.MaxTokens
+	}
+	return completionTokens
+}
+
+// estimateGEval projects the chain of thoughts and judge calls of a geval metric for a response of
+// responseTokens tokens.
+func (e *CostEstimator) estimateGEval(metricConfig domain.MetricConfig, expected string, responseTokens int) domain.CostEstimate {
+	estimate := domain.CostEstimate{}
+
+	geval := NewGEval(nil, gevalTaskPrompt, gevalCriteria(metricConfig))
+	chainOfThoughtsPromptTokens := llm.EstimateTextTokens(geval.chainOfThoughtsPrompt())
+	chainOfThoughtsCost, known := e.estimateCost(e.cfg.EvalProvider, e.cfg.EvalModel, chainOfThoughtsPromptTokens, estimatedChainOfThoughtsTokens)
+	estimate = estimate.Add(domain.CostEstimate{
+		Calls:            1,
+		PromptTokens:     chainOfThoughtsPromptTokens,
+		CompletionTokens: estimatedChainOfThoughtsTokens,
+		EvaluationCost:   chainOfThoughtsCost,
+		UnknownCost:      !known,
+	})
+
+	// The judge gets the evaluation prompt with the chain of thoughts and the response, and the response again as query
+	judgePromptTokens := llm.EstimateTextTokens(geval.buildPrompt(expected, "")) + estimatedChainOfThoughtsTokens + 2*responseTokens
+	judgeCost, known := e.estimateCost(e.cfg.EvalProvider, e.cfg.EvalModel, judgePromptTokens, estimatedJudgeResponseTokens)
+	estimate = estimate.Add(domain.CostEstimate{
+		Calls:            1,
+		PromptTokens:     judgePromptTokens,
+		CompletionTokens: estimatedJudgeResponseTokens,
+		EvaluationCost:   judgeCost,
+		UnknownCost:      !known,
+	})
+
+	return estimate
+}
+
+// estimateCost returns the projected cost with today's price of the model and whether the price is known.
+func (e *CostEstimator) estimateCost(provider, model string, promptTokens, completionTokens int) (float64, bool) {
+	price, ok := e.cfg.Pricing.Lookup(provider, model, time.Now())
+	if !ok {
+		return 0, false
+	}
+	return price.Cost(domain.TokenUsage{PromptTokens: promptTokens, CompletionTokens: completionTokens}), true
+}
+
+// unrenderedDiagramSize is the width and height a diagram that was not rendered yet is estimated
+// with, the largest image the providers take before downscaling.
+const unrenderedDiagramSize = 2048
+
+// estimateDiagramTokens estimates the diagrams of image mode without running the renderers. A
+// diagram rendered before, e.g. in an earlier run, is estimated from its cached PNG, any other as
+// an image of unrenderedDiagramSize.
+func (e *CostEstimator) estimateDiagramTokens(testSuiteConfig *domain.TestSuiteConfig, paths []string, options imaging.Options) (int, error) {
+	tokens := 0
+	for _, path := range paths {
+		kind, err := domain.DiagramKindOf(path)
+		if err != nil {
+			return 0, err
+		}
+		command := testSuiteConfig.Diagrams.Renderer(kind)
+		if command == "" {
+			return 0, fmt.Errorf("no renderer configured for %s diagrams", kind)
+		}
+		pngPath, rendered, err := diagram.Rendered(path, command, testSuiteConfig.Path, e.cfg.DiagramsPath)
+		if err != nil {
+			return 0, err
+		}
+		if !rendered {
+			for _, size := range imaging.Sizes(unrenderedDiagramSize, unrenderedDiagramSize, options) {
+				tokens += llm.EstimateImageTokens(size.X, size.Y)
+			}
+			continue
+		}
+		imageTokens, err := estimateImageTokens(pngPath, options)
+		if err != nil {
+			return 0, err
+		}
+		tokens += imageTokens
+	}
+	return tokens, nil
+}
+
+// estimateImageTokens estimates the tokens of an image after the preprocessing, i.e. of its tiles.
+func estimateImageTokens(imagePath string, options imaging.Options) (int, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return 0, fmt.Errorf("error opening image file: %w", err)
+	}
+	defer file.Close()
+
+	imageConfig, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, fmt.Errorf("error decoding image %s: %w", imagePath, err)
+	}
+	tokens := 0
+	for _, size := range imaging.Sizes(imageConfig.Width, imageConfig.Height, options) {
+		tokens += llm.EstimateImageTokens(size.X, size.Y)
+	}
+	return tokens, nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+// TestEstimateDoesNotRenderDiagrams checks that the estimate of image mode counts a diagram that was
+// not rendered yet without running its renderer.
+func TestEstimateDoesNotRenderDiagrams(t *testing.T) {
+	suiteDir := t.TempDir()
+	marker := filepath.Join(suiteDir, "rendered")
+	script := "#!/bin/sh\ntouch " + marker + "\n"
+	if err := os.WriteFile(filepath.Join(suiteDir, "render.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(suiteDir, "context.dsl"), []byte("workspace {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	testSuiteConfig := &domain.TestSuiteConfig{
+		Provider: "mock",
+		Model:    "mock-model",
+		Diagrams: domain.DiagramConfig{Mode: domain.DiagramModeImage, Renderers: map[domain.DiagramKind]string{domain.DiagramKindStructurizr: "./render.sh {input} {output}"}},
+		Path:     suiteDir,
+	}
+	testCaseConfig := &domain.TestCaseConfig{Name: "case", Path: suiteDir, Input: "Describe the diagram.", Diagrams: []string{"context.dsl"}}
+	estimator := NewCostEstimator(&domain.BenchmarkConfig{DiagramsPath: t.TempDir(), Pricing: &domain.PricingCatalog{}})
+
+	withDiagram, err := estimator.EstimateTestCase(testSuiteConfig, testCaseConfig)
+	if err != nil {
+		t.Fatalf("estimate: %v", err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Errorf("the renderer ran for the estimate")
+	}
+	testCaseConfig.Diagrams = nil
+	withoutDiagram, err := estimator.EstimateTestCase(testSuiteConfig, testCaseConfig)
+	if err != nil {
+		t.Fatalf("estimate: %v", err)
+	}
+	if withDiagram.PromptTokens <= withoutDiagram.PromptTokens {
+		t.Errorf("prompt tokens with the diagram %d, without %d, want the diagram counted", withDiagram.PromptTokens, withoutDiagram.PromptTokens)
+	}
+}
+
+// TestRepositoryPackedOnce checks that the repository of a test case is packed once, its
+// repetitions and its estimate share the packed document.
+func TestRepositoryPackedOnce(t *testing.T) {
+	caseDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(caseDir, "repo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(caseDir, "repo", "go.mod"), []byte("module example.com/repo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	testCaseConfig := &domain.TestCaseConfig{Name: "case", Path: caseDir, Repository: &domain.RepositoryConfig{Path: "repo"}}
+	packs := newRepositoryPacks()
+
+	first, err := packs.pack(testCaseConfig)
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(caseDir, "repo", "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	second, err := packs.pack(testCaseConfig)
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+	if second.Text != first.Text {
+		t.Errorf("repository packed again:\n%s", second.Text)
+	}
+
+	// Another test case in the same directory is packed on its own
+	other, err := packs.pack(&domain.TestCaseConfig{Name: "other", Path: caseDir, Repository: &domain.RepositoryConfig{Path: "repo"}})
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+	if other.Text == first.Text {
+		t.Errorf("other test case shares the packed repository of the first")
+	}
+}
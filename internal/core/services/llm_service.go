@@ -1,64 +1,158 @@
 package services
 
 import (
-	"encoding/base64"
+	"context"
 	"fmt"
-	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/document"
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/imaging"
 	"github.com/ingo-eichhorst/arch-bench/internal/adapters/llm"
 	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
 	"github.com/ingo-eichhorst/arch-bench/internal/core/ports"
 )
 
 type LLMService struct {
-	provider ports.LLMProvider
+	provider     ports.LLMProvider
+	providerName string
+	modelName    string
+	imageConfig  domain.ImageConfig
+	documents    []domain.Document
 }
 
-func NewLLMService(providerName string, ModelName string, cfg *domain.BenchmarkConfig) (*LLMService, error) {
-	var provider ports.LLMProvider
-	switch providerName {
-	case "openai":
-		APIKey := cfg.OpenAIAPIKey
-		if APIKey == "" {
-			return nil, fmt.Errorf("OpenAI API key is required")
-		}
-		provider = llm.NewOpenAIProvider(APIKey, ModelName)
-	default:
-		return nil, fmt.Errorf("unsupported LLM provider: %s", providerName)
+// LLMServiceOption configures the LLM service.
+type LLMServiceOption func(*LLMService)
+
+// WithImageConfig sets the preprocessing of the images, by default only the limits of the provider apply.
+func WithImageConfig(config domain.ImageConfig) LLMServiceOption {
+	return func(s *LLMService) {
+		s.imageConfig = config
+	}
+}
+
+// WithDocuments attaches loaded documents to every request of the service, e.g. a packed repository.
+func WithDocuments(documents ...domain.Document) LLMServiceOption {
+	return func(s *LLMService) {
+		s.documents = append(s.documents, documents...)
 	}
+}
 
-	return &LLMService{provider: provider}, nil
+func NewLLMService(factory *ProviderFactory, providerName string, ModelName string, opts ...LLMServiceOption) (*LLMService, error) {
+	provider, err := factory.NewProvider(providerName, ModelName)
+	if err != nil {
+		return nil, err
+	}
+	return newLLMService(provider, providerName, ModelName, opts...), nil
+}
+
+func newLLMService(provider ports.LLMProvider, providerName string, modelName string, opts ...LLMServiceOption) *LLMService {
+	service := &LLMService{
+		provider:     provider,
+		providerName: providerName,
+		modelName:    modelName,
+	}
+	for _, opt := range opts {
+		opt(service)
+	}
+	return service
+}
+
+// GenerateResponse calls the provider and returns the response together with a record of the call.
+// history holds the previous messages of a conversation. Images and documents are file paths, PDFs
+// are sent as file if the model supports it.
+func (s *LLMService) GenerateResponse(ctx context.Context, role domain.CallRole, systemPrompt string, history []domain.Message, query string, images []string, documents []string, generation domain.GenerationConfig) (domain.LLMResponse, domain.LLMCall, error) {
+	request, err := s.NewRequest(systemPrompt, history, query, images, documents, generation)
+	if err != nil {
+		return domain.LLMResponse{}, domain.LLMCall{}, err
+	}
+	return s.Generate(ctx, role, request)
 }
 
-func (s *LLMService) GenerateResponse(systemPrompt string, query string, images []string) (domain.LLMResponse, error) {
-	// Generate image embeddings (using a separate vision model)
-	base64Images := make([]string, len(images))
-	for i, imagePath := range images {
-		base64Image, err := encodeImageToBase64(imagePath)
+// NewRequest loads the images and documents of a request, so it can be sent several times, e.g.
+// in the tool loop.
+func (s *LLMService) NewRequest(systemPrompt string, history []domain.Message, query string, images []string, documents []string, generation domain.GenerationConfig) (domain.LLMRequest, error) {
+	// Images are scaled, tiled and converted to the limits of the provider, an image can become several tiles
+	imageOptions := imaging.NewOptions(s.imageConfig, llm.ImageLimits(s.providerName))
+	var encodedImages []domain.Image
+	for _, imagePath := range images {
+		processedImages, err := processImage(imagePath, imageOptions)
+		if err != nil {
+			return domain.LLMRequest{}, err
+		}
+		encodedImages = append(encodedImages, processedImages...)
+	}
+
+	nativePDF := llm.SupportsNativePDF(s.providerName, s.modelName)
+	loadedDocuments := make([]domain.Document, len(documents))
+	for i, documentPath := range documents {
+		loadedDocument, err := document.Load(documentPath, nativePDF)
 		if err != nil {
-			return domain.LLMResponse{}, fmt.Errorf("error encoding image to base64: %w", err)
+			return domain.LLMRequest{}, fmt.Errorf("error loading document %s: %w", documentPath, err)
 		}
-		base64Images[i] = base64Image
+		loadedDocuments[i] = loadedDocument
 	}
+	loadedDocuments = append(loadedDocuments, s.documents...)
+
+	return domain.LLMRequest{
+		SystemPrompt: systemPrompt,
+		History:      history,
+		Query:        query,
+		Images:       encodedImages,
+		Documents:    loadedDocuments,
+		Generation:   generation,
+	}, nil
+}
 
-	llmResponse, err := s.provider.GenerateResponse(systemPrompt, query, base64Images)
+// Generate sends a request to the provider and returns the response together with a record of the call.
+func (s *LLMService) Generate(ctx context.Context, role domain.CallRole, request domain.LLMRequest) (domain.LLMResponse, domain.LLMCall, error) {
+	startTime := time.Now()
+	llmResponse, err := s.provider.GenerateResponse(ctx, request)
 	if err != nil {
-		return domain.LLMResponse{}, fmt.Errorf("error calling LLM provider: %w", err)
+		return domain.LLMResponse{}, domain.LLMCall{}, fmt.Errorf("error calling LLM provider: %w", err)
 	}
-	return llmResponse, nil
+	call := s.newCall(role, llmResponse.Cost, llmResponse.CostUnknown, llmResponse.Usage, time.Since(startTime))
+	call.Cached = llmResponse.Cached
+	return llmResponse, call, nil
 }
 
-func encodeImageToBase64(imagePath string) (string, error) {
-	// Read the image file
-	imageData, err := ioutil.ReadFile(imagePath)
+// GenerateStructuredResponse calls the provider with a JSON schema for the response and returns
+// the parsed response together with a record of the call.
+func (s *LLMService) GenerateStructuredResponse(ctx context.Context, role domain.CallRole, request domain.LLMRequest, schema domain.StructuredOutput) (domain.StructuredResponse, domain.LLMCall, error) {
+	startTime := time.Now()
+	structuredResponse, err := s.provider.GenerateStructuredResponse(ctx, request, schema)
 	if err != nil {
-		return "", fmt.Errorf("error reading image file: %w", err)
+		return domain.StructuredResponse{}, domain.LLMCall{}, fmt.Errorf("error calling LLM provider: %w", err)
 	}
+	call := s.newCall(role, structuredResponse.Cost, structuredResponse.CostUnknown, structuredResponse.Usage, time.Since(startTime))
+	call.Cached = structuredResponse.Cached
+	return structuredResponse, call, nil
+}
 
-	// Encode the image to base64
-	encodedImage := base64.StdEncoding.EncodeToString(imageData)
+func (s *LLMService) newCall(role domain.CallRole, cost float64, costUnknown bool, usage domain.TokenUsage, duration time.Duration) domain.LLMCall {
+	return domain.LLMCall{
+		Role:        role,
+		Provider:    s.providerName,
+		Model:       s.modelName,
+		Cost:        cost,
+		CostUnknown: costUnknown,
+		Usage:       usage,
+		Duration:    duration,
+	}
+}
 
-	return encodedImage, nil
+func processImage(imagePath string, options imaging.Options) ([]domain.Image, error) {
+	imageData, err := os.ReadFile(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading image file: %w", err)
+	}
+
+	images, err := imaging.Process(imageData, options)
+	if err != nil {
+		return nil, fmt.Errorf("error processing image %s: %w", filepath.Base(imagePath), err)
+	}
+	return images, nil
 }
 
 func (s *LLMService) GetModels() []string {
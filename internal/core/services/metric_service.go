@@ -1,23 +1,38 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"path"
+	"strings"
 
 	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
 )
 
+const (
+	gevalTaskPrompt   = "Evaluate the quality of the generated text."
+	gevalEvalCriteria = "Coherence (1-5): evaluate the logical flow and connection between sentences."
+)
+
 type MetricService struct {
 	llmService *LLMService
+	// llmErr is why the judge provider could not be created, e.g. a missing API key. The judge
+	// metrics fail with it, so the run goes on and the results can be scored again with rescore.
+	llmErr error
 }
 
 func NewMetricService(
 	EvalProvider string,
 	EvalModel string,
-	cfg *domain.BenchmarkConfig,
+	factory *ProviderFactory,
 ) *MetricService {
-	llmService, _ := NewLLMService(EvalProvider, EvalModel, cfg)
+	provider, err := factory.NewEvalProvider(EvalProvider, EvalModel)
+	if err != nil {
+		return &MetricService{llmErr: err}
+	}
 	return &MetricService{
-		llmService: llmService,
+		llmService: newLLMService(provider, EvalProvider, EvalModel),
 	}
 }
 
@@ -27,6 +42,8 @@ type GEval struct {
 	TaskPrompt      string
 	EvalCriteria    string
 	ChainOfThoughts string
+	// Calls records every LLM call made by the evaluator
+	Calls []domain.LLMCall
 }
 
 // NewGEval creates a new G-Eval instance
@@ -39,16 +56,20 @@ func NewGEval(llmService *LLMService, taskPrompt, evalCriteria string) *GEval {
 }
 
 // GenerateChainOfThoughts generates the evaluation steps
-func (g *GEval) GenerateChainOfThoughts() error {
-	prompt := fmt.Sprintf("Given the task: %s\nAnd the evaluation criteria: %s\nGenerate a step-by-step chain of thoughts for evaluation:", g.TaskPrompt, g.EvalCriteria)
-	response, err := g.llmService.GenerateResponse("", prompt, nil)
+func (g *GEval) GenerateChainOfThoughts(ctx context.Context) error {
+	response, call, err := g.llmService.GenerateResponse(ctx, domain.CallRoleChainOfThought, "", nil, g.chainOfThoughtsPrompt(), nil, nil, domain.GenerationConfig{})
 	if err != nil {
 		return fmt.Errorf("failed to generate chain of thoughts: %v", err)
 	}
+	g.Calls = append(g.Calls, call)
 	g.ChainOfThoughts = response.Response
 	return nil
 }
 
+func (g *GEval) chainOfThoughtsPrompt() string {
+	return fmt.Sprintf("Given the task: %s\nAnd the evaluation criteria: %s\nGenerate a step-by-step chain of thoughts for evaluation:", g.TaskPrompt, g.EvalCriteria)
+}
+
 // buildPrompt constructs the full prompt for evaluation
 func (g *GEval) buildPrompt(context, target string) string {
 	return fmt.Sprintf(`%s
@@ -70,7 +91,7 @@ func (g *GEval) buildPrompt(context, target string) string {
 }
 
 // Evaluate performs the evaluation using structured output.
-func (g *GEval) Evaluate(context string, target string) (*domain.EvaluationResult, error) {
+func (g *GEval) Evaluate(ctx context.Context, evalContext string, target string) (*domain.EvaluationResult, error) {
 	var gevalSchemaVar = domain.StructuredOutput{
 		Type: "object",
 		Properties: domain.StructuredOutputProperties{
@@ -85,43 +106,81 @@ func (g *GEval) Evaluate(context string, target string) (*domain.EvaluationResul
 		AdditionalProperties: false,
 	}
 
-	structuredResponse, err := g.llmService.provider.GenerateStructuredResponse(g.buildPrompt(context, target), target, gevalSchemaVar)
+	structuredResponse, call, err := g.llmService.GenerateStructuredResponse(ctx, domain.CallRoleJudge, domain.LLMRequest{
+		SystemPrompt: g.buildPrompt(evalContext, target),
+		Query:        target,
+	}, gevalSchemaVar)
 	if err != nil {
 		return nil, fmt.Errorf("error generating structured response: %v", err)
 	}
+	g.Calls = append(g.Calls, call)
 
-	scoreInterface, ok := structuredResponse["score"]
+	scoreValue, ok := structuredResponse.Data["score"]
 	if !ok {
 		return nil, fmt.Errorf("score field not found in structured response")
 	}
+	score, ok := scoreValue.(float64)
+	if !ok {
+		return nil, fmt.Errorf("score %v in structured response is not a number", scoreValue)
+	}
 
-	return &domain.EvaluationResult{Score: scoreInterface.(float64)}, nil
+	return &domain.EvaluationResult{Score: score}, nil
 }
 
-func (s *MetricService) CalculateMetrics(response string, expected string) ([]domain.Metric, error) {
-	geval := NewGEval(s.llmService, "Evaluate the quality of the generated text.", "Coherence (1-5): evaluate the logical flow and connection between sentences.")
-	err := geval.GenerateChainOfThoughts()
-	if err != nil {
-		return nil, fmt.Errorf("error generating chain of thoughts: %v", err)
+// CalculateMetrics scores the response with the configured metrics and returns them together with
+// every LLM call made for the evaluation. The tool_trajectory metric is only scored for responses
+// with an expected trajectory.
+func (s *MetricService) CalculateMetrics(ctx context.Context, metricConfigs []domain.MetricConfig, response string, expected string, trajectory domain.Trajectory) ([]domain.Metric, []domain.LLMCall, error) {
+	var metrics []domain.Metric
+	var calls []domain.LLMCall
+
+	for _, metricConfig := range metricConfigs {
+		var value float64
+		switch metricConfig.Name {
+		case "geval":
+			if s.llmErr != nil {
+				return nil, calls, fmt.Errorf("error creating judge provider: %w", s.llmErr)
+			}
+			geval := NewGEval(s.llmService, gevalTaskPrompt, gevalCriteria(metricConfig))
+			err := geval.GenerateChainOfThoughts(ctx)
+			calls = append(calls, geval.Calls...)
+			if err != nil {
+				return nil, calls, fmt.Errorf("error generating chain of thoughts: %v", err)
+			}
+
+			geval.Calls = nil
+			result, err := geval.Evaluate(ctx, expected, response)
+			calls = append(calls, geval.Calls...)
+			if err != nil {
+				return nil, calls, fmt.Errorf("error evaluating response: %v", err)
+			}
+			value = result.Score
+		case "relevance":
+			value = calculateRelevance(expected, response)
+		case "tool_trajectory":
+			if len(trajectory.Expected) == 0 {
+				continue
+			}
+			value = calculateTrajectory(trajectory)
+		default:
+			return nil, calls, fmt.Errorf("unknown metric: %s", metricConfig.Name)
+		}
+
+		metrics = append(metrics, domain.Metric{
+			Name:   metricConfig.Name,
+			Value:  value,
+			Weight: metricConfig.Weight,
+		})
 	}
 
-	result, err := geval.Evaluate(expected, response)
-	if err != nil {
-		return nil, fmt.Errorf("error evaluating response: %v", err)
-	}
+	return metrics, calls, nil
+}
 
-	metrics := []domain.Metric{
-		{
-			Name:  "geval",
-			Value: result.Score,
-		},
-		{
-			Name:  "relevance",
-			Value: calculateRelevance(expected, response),
-		},
+func gevalCriteria(metricConfig domain.MetricConfig) string {
+	if metricConfig.Criteria != "" {
+		return metricConfig.Criteria
 	}
-
-	return metrics, nil
+	return gevalEvalCriteria
 }
 
 func calculateRelevance(expected, actual string) float64 {
@@ -130,3 +189,49 @@ func calculateRelevance(expected, actual string) float64 {
 	}
 	return 50.0
 }
+
+// calculateTrajectory is the share of the expected tool calls the model made, in any order. Every
+// call of the model matches at most one expected call.
+func calculateTrajectory(trajectory domain.Trajectory) float64 {
+	used := make([]bool, len(trajectory.Calls))
+	matched := 0
+	for _, expected := range trajectory.Expected {
+		for i, call := range trajectory.Calls {
+			if !used[i] && matchesToolCall(expected, call) {
+				used[i] = true
+				matched++
+				break
+			}
+		}
+	}
+	return 100 * float64(matched) / float64(len(trajectory.Expected))
+}
+
+// matchesToolCall reports whether the call has the name and the arguments of the expected call.
+// Paths are compared cleaned, so "./src/" matches "src".
+func matchesToolCall(expected domain.ExpectedToolCall, call domain.ToolCallRecord) bool {
+	if call.Name != expected.Name {
+		return false
+	}
+	var arguments map[string]any
+	if err := json.Unmarshal([]byte(call.Arguments), &arguments); err != nil && len(expected.Arguments) > 0 {
+		return false
+	}
+	for name, value := range expected.Arguments {
+		if normalizeArgument(name, arguments[name]) != normalizeArgument(name, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeArgument(name string, value any) string {
+	text := strings.TrimSpace(fmt.Sprint(value))
+	if value == nil {
+		text = ""
+	}
+	if name == "path" || name == "module" {
+		return path.Clean("/" + text)
+	}
+	return text
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+// judgeProvider answers the chain of thoughts with a fixed text and the judge with the given data.
+type judgeProvider struct {
+	data map[string]interface{}
+}
+
+func (p *judgeProvider) GenerateResponse(ctx context.Context, request domain.LLMRequest) (domain.LLMResponse, error) {
+	return domain.LLMResponse{Response: "1. Read the text."}, nil
+}
+
+func (p *judgeProvider) GenerateStructuredResponse(ctx context.Context, request domain.LLMRequest, schema domain.StructuredOutput) (domain.StructuredResponse, error) {
+	return domain.StructuredResponse{Data: p.data}, nil
+}
+
+func (p *judgeProvider) GetModels() []string {
+	return nil
+}
+
+func TestCalculateMetricsGEvalScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		want    float64
+		wantErr string
+	}{
+		{name: "number", data: map[string]interface{}{"score": 80.0}, want: 80},
+		{name: "string", data: map[string]interface{}{"score": "8"}, wantErr: "not a number"},
+		{name: "null", data: map[string]interface{}{"score": nil}, wantErr: "not a number"},
+		{name: "missing", data: map[string]interface{}{}, wantErr: "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metricService := &MetricService{llmService: &LLMService{provider: &judgeProvider{data: tt.data}}}
+			metrics, calls, err := metricService.CalculateMetrics(context.Background(), []domain.MetricConfig{{Name: "geval"}}, "response", "expected", domain.Trajectory{})
+
+			if len(calls) != 2 {
+				t.Errorf("got %d calls, want the chain of thoughts and the judge call", len(calls))
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("calculate metrics: %v", err)
+			}
+			if len(metrics) != 1 || metrics[0].Value != tt.want {
+				t.Errorf("metrics = %+v, want geval %v", metrics, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateTrajectory(t *testing.T) {
+	expected := []domain.ExpectedToolCall{
+		{Name: "dependencies", Arguments: map[string]any{"module": "internal/core"}},
+		{Name: "read_file", Arguments: map[string]any{"path": "go.mod"}},
+	}
+	tests := []struct {
+		name     string
+		expected []domain.ExpectedToolCall
+		calls    []domain.ToolCallRecord
+		want     float64
+	}{
+		{
+			name:     "all calls in another order",
+			expected: expected,
+			calls:    []domain.ToolCallRecord{{Name: "read_file", Arguments: `{"path": "go.mod"}`}, {Name: "list_modules", Arguments: `{}`}, {Name: "dependencies", Arguments: `{"module": "internal/core"}`}},
+			want:     100,
+		},
+		{
+			name:     "cleaned paths match",
+			expected: expected,
+			calls:    []domain.ToolCallRecord{{Name: "dependencies", Arguments: `{"module": "./internal/core/"}`}, {Name: "read_file", Arguments: `{"path": "/go.mod"}`}},
+			want:     100,
+		},
+		{
+			name:     "other arguments",
+			expected: expected,
+			calls:    []domain.ToolCallRecord{{Name: "dependencies", Arguments: `{"module": "internal/adapters"}`}, {Name: "read_file", Arguments: `{"path": "go.mod"}`}},
+			want:     50,
+		},
+		{
+			name:     "only the given arguments are compared",
+			expected: []domain.ExpectedToolCall{{Name: "search"}},
+			calls:    []domain.ToolCallRecord{{Name: "search", Arguments: `{"pattern": "func"}`}},
+			want:     100,
+		},
+		{
+			name:     "a call matches one expected call",
+			expected: []domain.ExpectedToolCall{{Name: "list_modules"}, {Name: "list_modules"}},
+			calls:    []domain.ToolCallRecord{{Name: "list_modules", Arguments: `{}`}},
+			want:     50,
+		},
+		{
+			name:     "unparsable arguments",
+			expected: expected[:1],
+			calls:    []domain.ToolCallRecord{{Name: "dependencies", Arguments: `{"module": `}},
+			want:     0,
+		},
+		{
+			name:     "no calls",
+			expected: expected,
+			want:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calculateTrajectory(domain.Trajectory{Calls: tt.calls, Expected: tt.expected}); got != tt.want {
+				t.Errorf("trajectory = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCalculateMetricsWithoutJudge checks that a judge provider that cannot be created fails the
+// judge metrics with its error, while the other metrics are still scored.
+func TestCalculateMetricsWithoutJudge(t *testing.T) {
+	factory := NewProviderFactory(&domain.BenchmarkConfig{Pricing: &domain.PricingCatalog{}})
+	metricService := NewMetricService("openai", "gpt-4o-mini", factory)
+
+	_, _, err := metricService.CalculateMetrics(context.Background(), []domain.MetricConfig{{Name: "relevance"}, {Name: "geval"}}, "response", "expected", domain.Trajectory{})
+	if err == nil || !strings.Contains(err.Error(), "API key is required") {
+		t.Fatalf("error = %v, want the missing API key", err)
+	}
+
+	metrics, _, err := metricService.CalculateMetrics(context.Background(), []domain.MetricConfig{{Name: "relevance"}}, "response", "expected", domain.Trajectory{})
+	if err != nil || len(metrics) != 1 {
+		t.Errorf("relevance = %+v, %v, want it scored without a judge", metrics, err)
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/llm"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/ports"
+)
+
+// ProviderFactory creates the LLM providers of a benchmark run. Every provider is wrapped with the
+// resilience layer, whose rate limits and circuit breaker are shared by all models of a provider,
+// with the fixture recorder and with the response cache if they are enabled. While recording the
+// cache is off, so every response is recorded. In replay mode the responses come from the fixtures
+// only.
+type ProviderFactory struct {
+	cfg        *domain.BenchmarkConfig
+	mu         sync.Mutex
+	resilience map[string]*llm.Resilience
+}
+
+func NewProviderFactory(cfg *domain.BenchmarkConfig) *ProviderFactory {
+	return &ProviderFactory{
+		cfg:        cfg,
+		resilience: make(map[string]*llm.Resilience),
+	}
+}
+
+func (f *ProviderFactory) NewProvider(providerName string, modelName string) (ports.LLMProvider, error) {
+	return f.newProvider(providerName, modelName, f.cfg.Providers[providerName])
+}
+
+// NewEvalProvider creates the provider of the judge. The eval API key, if set, replaces the API key
+// of the provider, e.g. to bill the evaluation to another account than the models under test.
+func (f *ProviderFactory) NewEvalProvider(providerName string, modelName string) (ports.LLMProvider, error) {
+	credentials := f.cfg.Providers[providerName]
+	if f.cfg.EvalApiKey != "" {
+		credentials.APIKey = f.cfg.EvalApiKey
+	}
+	return f.newProvider(providerName, modelName, credentials)
+}
+
+func (f *ProviderFactory) newProvider(providerName string, modelName string, credentials domain.ProviderConfig) (ports.LLMProvider, error) {
+	if f.cfg.ReplayMode == domain.ReplayModeReplay {
+		// Served from the fixtures, the provider itself is never called
+		return llm.NewReplayProvider(nil, f.cfg.FixturesPath, providerName, modelName, f.cfg.ReplayMode), nil
+	}
+
+	var provider ports.LLMProvider
+	switch providerName {
+	case "openai":
+		if credentials.APIKey == "" {
+			return nil, fmt.Errorf("OpenAI API key is required, set OPENAI_API_KEY or providers.openai.api_key")
+		}
+		provider = llm.NewOpenAIProvider(credentials.APIKey, modelName, f.cfg.Pricing, llm.WithBaseURL(credentials.BaseURL))
+	case "mock":
+		provider = llm.NewMockProvider(modelName)
+	default:
+		return nil, fmt.Errorf("unsupported LLM provider: %s", providerName)
+	}
+
+	provider = llm.NewResilientProvider(provider, f.providerResilience(providerName))
+	if f.cfg.ReplayMode == domain.ReplayModeRecord {
+		provider = llm.NewReplayProvider(provider, f.cfg.FixturesPath, providerName, modelName, f.cfg.ReplayMode)
+	}
+	if f.cfg.CacheMode != "" && f.cfg.CacheMode != domain.CacheModeOff && f.cfg.ReplayMode != domain.ReplayModeRecord {
+		// Outside of the resilience layer, so cache hits do not count against the rate limits
+		provider = llm.NewCachingProvider(provider, f.cfg.CachePath, providerName, modelName, f.cfg.CacheMode)
+	}
+	return provider, nil
+}
+
+func (f *ProviderFactory) providerResilience(providerName string) *llm.Resilience {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	resilience, ok := f.resilience[providerName]
+	if !ok {
+		resilience = llm.NewResilience(f.cfg.Resilience[providerName], f.cfg.CallTimeout())
+		f.resilience[providerName] = resilience
+	}
+	return resilience
+}
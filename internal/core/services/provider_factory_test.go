@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+// TestProviderFactoryRecordsCachedResponses checks that a response in the cache is still recorded as
+// a fixture, the cache is off while recording.
+func TestProviderFactoryRecordsCachedResponses(t *testing.T) {
+	cfg := &domain.BenchmarkConfig{
+		CacheMode:    domain.CacheModeReadWrite,
+		CachePath:    t.TempDir(),
+		FixturesPath: t.TempDir(),
+	}
+	generate := func() {
+		t.Helper()
+		provider, err := NewProviderFactory(cfg).NewProvider("mock", "mock-model")
+		if err != nil {
+			t.Fatalf("new provider: %v", err)
+		}
+		if _, err := provider.GenerateResponse(context.Background(), domain.LLMRequest{Query: "q"}); err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+	}
+
+	generate()
+	cached, _ := filepath.Glob(filepath.Join(cfg.CachePath, "*", "*.json"))
+	if len(cached) != 1 {
+		t.Fatalf("got %d cached responses, want 1", len(cached))
+	}
+
+	cfg.ReplayMode = domain.ReplayModeRecord
+	generate()
+	fixtures, _ := filepath.Glob(filepath.Join(cfg.FixturesPath, "mock", "mock-model", "*.json"))
+	if len(fixtures) != 1 {
+		t.Errorf("got %d fixtures, want the cached response recorded", len(fixtures))
+	}
+}
+
+func TestProviderFactoryEvalAPIKey(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		evalAPIKey string
+		eval       bool
+		want       string
+	}{
+		{name: "model under test", evalAPIKey: "eval-key", want: "Bearer provider-key"},
+		{name: "judge", evalAPIKey: "eval-key", eval: true, want: "Bearer eval-key"},
+		{name: "judge without eval key", eval: true, want: "Bearer provider-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factory := NewProviderFactory(&domain.BenchmarkConfig{
+				EvalApiKey: tt.evalAPIKey,
+				Pricing:    &domain.PricingCatalog{},
+				Providers:  map[string]domain.ProviderConfig{"openai": {APIKey: "provider-key", BaseURL: server.URL + "/v1"}},
+			})
+			newProvider := factory.NewProvider
+			if tt.eval {
+				newProvider = factory.NewEvalProvider
+			}
+			provider, err := newProvider("openai", "gpt-4o")
+			if err != nil {
+				t.Fatalf("new provider: %v", err)
+			}
+			if _, err := provider.GenerateResponse(context.Background(), domain.LLMRequest{Query: "q"}); err != nil {
+				t.Fatalf("generate: %v", err)
+			}
+			if authorization != tt.want {
+				t.Errorf("authorization = %q, want %q", authorization, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/llm"
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/report"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+// RescoreBenchmark evaluates the stored outputs of a previous run again with the current metric
+// configuration, without calling the models under test. The result is written as a new run that
+// links to the run the outputs were generated in. Test suites that no longer exist in the
+// configuration are scored with the default metrics.
+func (s *BenchmarkService) RescoreBenchmark(ctx context.Context, source *domain.Benchmark) error {
+	sourceRunID := source.RunID
+	if source.SourceRunID != "" {
+		sourceRunID = source.SourceRunID
+	}
+
+	fmt.Printf("Rescoring Benchmark: %s (run %s)\n", s.cfg.Name, source.RunID)
+	fmt.Printf("- Eval Provider: %s\n", s.cfg.EvalProvider)
+	fmt.Printf("- Eval Model: %s\n", s.cfg.EvalModel)
+	fmt.Printf("----------------------\n")
+
+	if timeout := s.cfg.RunTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	startedAt := time.Now()
+	var completedTestSuites []*domain.TestSuite
+	status := domain.RunStatusCompleted
+	var stopReason string
+	stdOutReport := report.NewStdoutReportCreator()
+
+	for _, sourceSuite := range source.TestSuites {
+		testSuite, err := s.RescoreTestSuite(ctx, sourceSuite)
+		var budgetErr *BudgetExceededError
+		if err != nil && (ctx.Err() != nil || errors.As(err, &budgetErr)) {
+			if budgetErr != nil {
+				status, stopReason = domain.RunStatusBudgetExceeded, budgetErr.Error()
+			} else {
+				status, stopReason = stopStatus(ctx, s.cfg.RunTimeout())
+			}
+			fmt.Printf("Stopping rescoring: %s\n", stopReason)
+			if len(testSuite.TestCases) > 0 {
+				stdOutReport.GenerateTestSuiteReport(testSuite)
+				completedTestSuites = append(completedTestSuites, testSuite)
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error rescoring test suite %s: %v", sourceSuite.Name, err)
+		}
+		stdOutReport.GenerateTestSuiteReport(testSuite)
+		completedTestSuites = append(completedTestSuites, testSuite)
+	}
+
+	return s.writeReports(&domain.Benchmark{
+		Name:         s.cfg.Name,
+		RunID:        domain.NewRunID(startedAt),
+		BenchmarkDir: s.cfg.Dir,
+		SourceRunID:  sourceRunID,
+		StartedAt:    startedAt,
+		EvalProvider: s.cfg.EvalProvider,
+		EvalModel:    s.cfg.EvalModel,
+		Status:       status,
+		StopReason:   stopReason,
+		TestSuites:   completedTestSuites,
+	})
+}
+
+// RescoreTestSuite scores every stored result of the test suite again. On error the test cases
+// that were scored so far are still returned.
+func (s *BenchmarkService) RescoreTestSuite(ctx context.Context, source *domain.TestSuite) (*domain.TestSuite, error) {
+	fmt.Printf("Rescoring Test Suite: %s\n", source.Name)
+
+	metricConfigs := domain.DefaultMetricConfigs
+	var passThreshold float64
+	testSuiteConfig := s.testSuiteConfig(source.Name)
+	// Test suites that no longer exist are only limited by the benchmark budget
+	budgetSuite := &domain.TestSuiteConfig{Name: source.Name}
+	if testSuiteConfig != nil {
+		metricConfigs = testSuiteConfig.Metrics()
+		passThreshold = testSuiteConfig.PassThreshold
+		budgetSuite = testSuiteConfig
+	}
+	suiteStartCost := s.spent
+
+	testSuite := &domain.TestSuite{
+		Name:      source.Name,
+		Provider:  source.Provider,
+		Model:     source.Model,
+		TestCases: make([]domain.TestCase, 0, len(source.TestCases)),
+	}
+
+	for _, sourceCase := range source.TestCases {
+		testCase := domain.TestCase{
+			Name:              sourceCase.Name,
+			Input:             sourceCase.Input,
+			Expected:          sourceCase.Expected,
+			Tags:              sourceCase.Tags,
+			Conversation:      sourceCase.Conversation,
+			ExpectedToolCalls: sourceCase.ExpectedToolCalls,
+			PassThreshold:     passThreshold,
+			Results:           make([]*domain.TestResult, 0, len(sourceCase.Results)),
+		}
+
+		caseCtx := ctx
+		caseMetricConfigs := metricConfigs
+		if testCaseConfig := testSuiteConfig.TestCaseConfig(sourceCase.Name); testCaseConfig != nil {
+			caseCtx = llm.WithMockConfig(ctx, testCaseConfig.Mock)
+			caseMetricConfigs = testSuiteConfig.CaseMetrics(testCaseConfig)
+		}
+
+		var err error
+		for _, sourceResult := range sourceCase.Results {
+			if err = ctx.Err(); err != nil {
+				break
+			}
+			fmt.Printf("Rescoring Test Case: %s (repetition %d)\n", sourceCase.Name, sourceResult.Repetition)
+			if err = s.checkRescoreBudget(budgetSuite, caseMetricConfigs, sourceCase, sourceResult, suiteStartCost); err != nil {
+				break
+			}
+
+			result, rescoreErr := s.rescoreResult(caseCtx, caseMetricConfigs, sourceCase, sourceResult)
+			if rescoreErr != nil {
+				var testCaseErr *TestCaseError
+				if s.cfg.FailFast || ctx.Err() != nil || !errors.As(rescoreErr, &testCaseErr) {
+					err = rescoreErr
+					break
+				}
+				fmt.Printf("Test case %s failed (%s): %v\n", sourceCase.Name, testCaseErr.Category, rescoreErr)
+				result.Error = &domain.TestError{Category: testCaseErr.Category, Message: rescoreErr.Error()}
+			}
+			testCase.Results = append(testCase.Results, result)
+			s.spent += result.TotalCost()
+		}
+
+		if len(testCase.Results) > 0 {
+			testSuite.TestCases = append(testSuite.TestCases, testCase)
+		}
+		if err != nil {
+			return testSuite, fmt.Errorf("error rescoring test case %s: %w", sourceCase.Name, err)
+		}
+	}
+	fmt.Printf("----------------------\n")
+
+	return testSuite, nil
+}
+
+// checkRescoreBudget returns a BudgetExceededError if the estimated cost of scoring the stored result
+// again would exceed the benchmark or the test suite budget. Results that keep their error are not scored.
+func (s *BenchmarkService) checkRescoreBudget(testSuiteConfig *domain.TestSuiteConfig, metricConfigs []domain.MetricConfig, sourceCase domain.TestCase, source *domain.TestResult, suiteStartCost float64) error {
+	if s.cfg.MaxCost <= 0 && testSuiteConfig.MaxCost <= 0 {
+		return nil
+	}
+	if source.Failed() && source.Error.Category != domain.ErrorCategoryEvaluation {
+		return nil
+	}
+	estimate := s.estimator.EstimateEvaluation(metricConfigs, sourceCase.Expected, source)
+	return s.checkEstimate(testSuiteConfig, estimate, suiteStartCost)
+}
+
+// rescoreResult keeps the output, the generation calls and the tool calls of the stored result and
+// replaces its metrics and evaluation calls. The generation calls are marked as reused. Results whose generation failed have no output and keep
+// their error.
+func (s *BenchmarkService) rescoreResult(ctx context.Context, metricConfigs []domain.MetricConfig, sourceCase domain.TestCase, source *domain.TestResult) (*domain.TestResult, error) {
+	result := &domain.TestResult{
+		Repetition: source.Repetition,
+		Output:     source.Output,
+		Duration:   source.Duration,
+		Generation: source.Generation,
+		ToolCalls:  source.ToolCalls,
+	}
+	for _, call := range source.Calls {
+		if !call.Role.IsEvaluation() {
+			// Made in the source run, the rescored run only pays for the evaluation
+			call.Reused = true
+			result.AddCall(call)
+		}
+	}
+	if source.Failed() && source.Error.Category != domain.ErrorCategoryEvaluation {
+		result.Error = source.Error
+		return result, nil
+	}
+	if len(source.Turns) > 0 {
+		// The scored answers of a conversation keep their expected answers
+		for _, turn := range source.Turns {
+			turn.Metrics = nil
+			result.Turns = append(result.Turns, turn)
+		}
+		return result, s.scoreTurns(llm.WithCacheSample(ctx, source.Repetition), metricConfigs, result)
+	}
+
+	metrics, evaluationCalls, err := s.metricService.CalculateMetrics(
+		llm.WithCacheSample(ctx, source.Repetition),
+		metricConfigs,
+		source.Output,
+		sourceCase.Expected,
+		domain.Trajectory{Calls: source.ToolCalls, Expected: sourceCase.ExpectedToolCalls},
+	)
+	for _, call := range evaluationCalls {
+		result.AddCall(call)
+	}
+	if err != nil {
+		return result, newTestCaseError(domain.ErrorCategoryEvaluation, "error calculating metrics: %w", err)
+	}
+	result.Metrics = metrics
+
+	return result, nil
+}
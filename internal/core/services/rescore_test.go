@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/report"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+// sourceRun is a stored run of a single test case whose generation cost $1.
+func sourceRun() *domain.Benchmark {
+	result := &domain.TestResult{Repetition: 1, Output: "A layered architecture."}
+	result.AddCall(domain.LLMCall{Role: domain.CallRoleGeneration, Provider: "mock", Model: "mock-model", Cost: 1, Usage: domain.TokenUsage{PromptTokens: 100, CompletionTokens: 10}})
+	result.AddCall(domain.LLMCall{Role: domain.CallRoleJudge, Provider: "mock", Model: "mock-judge", Cost: 0.5})
+	return &domain.Benchmark{
+		Name:  "rescore",
+		RunID: "20260101-120000-0001",
+		TestSuites: []*domain.TestSuite{{
+			Name: "suite",
+			TestCases: []domain.TestCase{{
+				Name:     "case",
+				Expected: "A layered architecture.",
+				Results:  []*domain.TestResult{result},
+			}},
+		}},
+	}
+}
+
+func newRescoreService(t *testing.T, maxCost float64) *BenchmarkService {
+	t.Helper()
+	return NewBenchmarkService(&domain.BenchmarkConfig{
+		Name:         "rescore",
+		EvalProvider: "mock",
+		EvalModel:    "mock-judge",
+		ResultsPath:  t.TempDir(),
+		MaxCost:      maxCost,
+		Pricing: &domain.PricingCatalog{Prices: []domain.ModelPrice{
+			{Provider: "mock", Model: "mock-judge", InputCostPerMillion: 1000, OutputCostPerMillion: 1000, EffectiveDate: "2024-01-01"},
+		}},
+	})
+}
+
+func TestRescoreReusesGenerationCalls(t *testing.T) {
+	service := newRescoreService(t, 0)
+
+	testSuite, err := service.RescoreTestSuite(context.Background(), sourceRun().TestSuites[0])
+	if err != nil {
+		t.Fatalf("rescore: %v", err)
+	}
+	result := testSuite.TestCases[0].Results[0]
+	if result.GenerationCost != 0 || result.Usage.TotalTokens() != 0 {
+		t.Errorf("rescored result counts the source generation: cost %v, usage %+v", result.GenerationCost, result.Usage)
+	}
+	var reused, evaluations int
+	for _, call := range result.Calls {
+		switch {
+		case call.Reused && call.Role == domain.CallRoleGeneration:
+			reused++
+		case call.Role.IsEvaluation():
+			evaluations++
+		}
+	}
+	if reused != 1 || evaluations != 2 {
+		t.Errorf("got %d reused generation calls and %d evaluation calls, want 1 and 2", reused, evaluations)
+	}
+	if result.Output != "A layered architecture." || len(result.Metrics) == 0 {
+		t.Errorf("rescored result = %+v", result)
+	}
+}
+
+func TestRescoreStopsAtBudget(t *testing.T) {
+	service := newRescoreService(t, 0.01)
+
+	if err := service.RescoreBenchmark(context.Background(), sourceRun()); err != nil {
+		t.Fatalf("rescore: %v", err)
+	}
+	reports, err := filepath.Glob(filepath.Join(service.cfg.ResultsPath, "*", "report.json"))
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("got reports %v (%v), want one", reports, err)
+	}
+	rescored, err := report.LoadBenchmarkReport(reports[0])
+	if err != nil {
+		t.Fatalf("load report: %v", err)
+	}
+	if rescored.Status != domain.RunStatusBudgetExceeded || len(rescored.TestSuites) != 0 {
+		t.Errorf("status %s with %d test suites, want %s before the first judge call", rescored.Status, len(rescored.TestSuites), domain.RunStatusBudgetExceeded)
+	}
+	if rescored.SourceRunID != "20260101-120000-0001" {
+		t.Errorf("source run = %q", rescored.SourceRunID)
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ingo-eichhorst/arch-bench/internal/adapters/repository"
+	"github.com/ingo-eichhorst/arch-bench/internal/core/domain"
+)
+
+// errMaxToolCalls is returned when the model makes more tool calls than allowed.
+var errMaxToolCalls = errors.New("tool call limit exceeded")
+
+// recordedToolOutput is the length of the tool results that is kept in the trajectory of a result.
+const recordedToolOutput = 500
+
+// newToolbox opens the fixture of a test case with tools.
+func newToolbox(testCaseConfig *domain.TestCaseConfig) (*repository.Toolbox, error) {
+	fixture, err := repository.Open(filepath.Join(testCaseConfig.Path, testCaseConfig.Tools.Fixture))
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewToolbox(fixture, testCaseConfig.Tools.Allow)
+}
+
+// runToolLoop sends the request with the tools of the toolbox. The tool calls of the model are
+// executed and their results sent back until the model answers without calling a tool. Every LLM
+// call and tool call is recorded in the result.
+func runToolLoop(ctx context.Context, llmService *LLMService, toolbox *repository.Toolbox, maxCalls int, request domain.LLMRequest, result *domain.TestResult) (domain.LLMResponse, error) {
+	if maxCalls <= 0 {
+		maxCalls = domain.DefaultMaxToolCalls
+	}
+	request.Tools = toolbox.Definitions()
+
+	for {
+		llmResponse, call, err := llmService.Generate(ctx, domain.CallRoleGeneration, request)
+		if err != nil {
+			return domain.LLMResponse{}, err
+		}
+		result.AddCall(call)
+		if len(llmResponse.ToolCalls) == 0 {
+			return llmResponse, nil
+		}
+		if len(result.ToolCalls)+len(llmResponse.ToolCalls) > maxCalls {
+			return llmResponse, fmt.Errorf("%w: the model made more than %d tool calls", errMaxToolCalls, maxCalls)
+		}
+
+		request.ToolMessages = append(request.ToolMessages, domain.Message{
+			Role:      domain.RoleAssistant,
+			Content:   llmResponse.Response,
+			ToolCalls: llmResponse.ToolCalls,
+		})
+		for _, toolCall := range llmResponse.ToolCalls {
+			record := domain.ToolCallRecord{Name: toolCall.Name, Arguments: toolCall.Arguments}
+			output, err := toolbox.Execute(toolCall)
+			if err != nil {
+				// The model gets the error as result and can try again
+				record.Error = err.Error()
+				output = "error: " + err.Error()
+			}
+			record.Output = shorten(output, recordedToolOutput)
+			result.ToolCalls = append(result.ToolCalls, record)
+			request.ToolMessages = append(request.ToolMessages, domain.Message{
+				Role:       domain.RoleTool,
+				Content:    output,
+				ToolCallID: toolCall.ID,
+			})
+		}
+	}
+}
+
+func shorten(text string, length int) string {
+	if len(text) <= length {
+		return text
+	}
+	return strings.ToValidUTF8(text[:length], "") + "..."
+}